@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchConfig задаёт прогоны Bench: каждый размер входа с каждым ограничением workers.
+type BenchConfig struct {
+	Sizes   []int
+	Workers []int   // 0 — без ограничения
+	Scale   float64 // во сколько раз укоротить паузы DataSignerCrc32 и DataSignerMd5
+}
+
+// BenchResult — итог одного прогона SingleHash -> MultiHash.
+type BenchResult struct {
+	Inputs     int
+	Workers    int
+	Duration   time.Duration
+	Throughput float64       // значений в секунду
+	P50, P99   time.Duration // от подачи значения до выхода его MultiHash
+	Goroutines int           // пик runtime.NumGoroutine за прогон
+}
+
+// Bench прогоняет SingleHash -> MultiHash на подписях, ведущих себя как DataSignerCrc32 и DataSignerMd5
+// (md5 строго по одному), но с паузами, укороченными в cfg.Scale раз.
+func Bench(ctx context.Context, cfg BenchConfig) ([]BenchResult, error) {
+	scale := cfg.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	crc, md5 := NewCRC32Signer(""), NewMD5Signer("")
+	h := NewHasher(
+		WithChecksum(sleepySigner(crc, time.Duration(float64(time.Second)/scale))),
+		WithDigest(LimitedSigner(sleepySigner(md5, time.Duration(float64(10*time.Millisecond)/scale)),
+			NewLimiter(WithMaxInFlight(1)))))
+	instant := NewHasher(WithChecksum(crc), WithDigest(md5))
+
+	var results []BenchResult
+	for _, size := range cfg.Sizes {
+		inputs := make([]int, size)
+		for i := range inputs {
+			inputs[i] = i
+		}
+		expected, err := Then(NewPipeline(instant.SingleHashStage(WithOrdered())),
+			instant.MultiHashStage(WithOrdered())).Run(ctx, inputs...)
+		if err != nil {
+			return nil, err
+		}
+		// по результату MultiHash находим вход, чтобы посчитать задержку
+		index := make(map[string]int, size)
+		for i, res := range expected {
+			index[res] = i
+		}
+
+		for _, workers := range cfg.Workers {
+			res, err := benchRun(ctx, h, inputs, index, workers)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+func benchRun(ctx context.Context, h *Hasher, inputs []int, index map[string]int, workers int) (BenchResult, error) {
+	starts := make([]time.Time, len(inputs))
+	latencies := make([]time.Duration, 0, len(inputs))
+
+	peak := runtime.NumGoroutine()
+	stop := make(chan struct{})
+	sampled := sync.WaitGroup{}
+	sampled.Add(1)
+	go func() {
+		defer sampled.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n := runtime.NumGoroutine(); n > peak {
+					peak = n
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	err := ExecuteStages(ctx,
+		func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+			for i, val := range inputs {
+				starts[i] = time.Now()
+				if err := send[interface{}](ctx, out, val); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		erase(h.SingleHashStage(WithWorkers(workers))),
+		erase(h.MultiHashStage(WithWorkers(workers))),
+		func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+			for val := range in {
+				i, ok := index[val.(string)]
+				if !ok {
+					return ItemError(val, fmt.Errorf("unexpected result"))
+				}
+				latencies = append(latencies, time.Since(starts[i]))
+			}
+			return nil
+		})
+	duration := time.Since(start)
+	close(stop)
+	sampled.Wait()
+	if err != nil {
+		return BenchResult{}, err
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return BenchResult{
+		Inputs:     len(inputs),
+		Workers:    workers,
+		Duration:   duration,
+		Throughput: float64(len(inputs)) / duration.Seconds(),
+		P50:        percentile(latencies, 0.5),
+		P99:        percentile(latencies, 0.99),
+		Goroutines: peak,
+	}, nil
+}
+
+// percentile берёт значение из отсортированного sorted, не меньшее доли q всех значений.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// sleepySigner вызывает s и затем спит d, как глобальные DataSigner*, но с учётом ctx.
+func sleepySigner(s Signer, d time.Duration) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		res, err := s.Sign(ctx, data)
+		if err != nil {
+			return "", err
+		}
+		return res, sleepContext(ctx, d)
+	})
+}
+
+// WriteBenchTable выводит результаты Bench таблицей.
+func WriteBenchTable(w io.Writer, results []BenchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "inputs\tworkers\tduration\titems/s\tp50\tp99\tgoroutines\t")
+	for _, res := range results {
+		workers := "-"
+		if res.Workers > 0 {
+			workers = fmt.Sprint(res.Workers)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%.1f\t%s\t%s\t%d\t\n", res.Inputs, workers,
+			res.Duration.Round(time.Microsecond), res.Throughput,
+			res.P50.Round(time.Microsecond), res.P99.Round(time.Microsecond), res.Goroutines)
+	}
+	return tw.Flush()
+}
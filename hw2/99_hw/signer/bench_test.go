@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBenchScheduling ловит регрессии планирования: при scale=100 crc32 спит 10ms,
+// и без ограничения workers прогон не должен заметно зависеть от числа значений.
+func TestBenchScheduling(t *testing.T) {
+	results, err := Bench(context.Background(), BenchConfig{Sizes: []int{50}, Workers: []int{0, 5}, Scale: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("wrong result count\nGot: %d\nExpected: 2", len(results))
+	}
+
+	unlimited, limited := results[0], results[1]
+	if unlimited.Duration > 300*time.Millisecond {
+		t.Errorf("values are not processed in parallel\nGot: %s\nExpected: <%s", unlimited.Duration, 300*time.Millisecond)
+	}
+	if limited.Duration < unlimited.Duration {
+		t.Errorf("workers limit was ignored\nGot: %s\nExpected: >=%s", limited.Duration, unlimited.Duration)
+	}
+	if unlimited.P50 > unlimited.P99 || unlimited.Goroutines < 50 {
+		t.Errorf("unexpected stats: %+v", unlimited)
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteBenchTable(out, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "items/s") {
+		t.Errorf("unexpected table:\n%s", out)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		for _, workers := range []int{0, 4, 16} {
+			b.Run(fmt.Sprintf("inputs=%d/workers=%d", size, workers), func(b *testing.B) {
+				cfg := BenchConfig{Sizes: []int{size}, Workers: []int{workers}, Scale: 100}
+				var p50, p99, goroutines float64
+				for i := 0; i < b.N; i++ {
+					results, err := Bench(context.Background(), cfg)
+					if err != nil {
+						b.Fatal(err)
+					}
+					p50 += float64(results[0].P50.Microseconds())
+					p99 += float64(results[0].P99.Microseconds())
+					goroutines += float64(results[0].Goroutines)
+				}
+				b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "items/s")
+				b.ReportMetric(p50/float64(b.N), "p50-µs")
+				b.ReportMetric(p99/float64(b.N), "p99-µs")
+				b.ReportMetric(goroutines/float64(b.N), "goroutines")
+			})
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+)
+
+// Pipeline — типизированный конвейер, собранный из стадий через NewPipeline и Then.
+// Совместимость типов соседних стадий проверяется при компиляции.
+type Pipeline[In, Out any] struct {
+	stages []stage
+}
+
+// NewPipeline начинает конвейер со стадии first.
+func NewPipeline[In, Out any](first Stage[In, Out]) *Pipeline[In, Out] {
+	return &Pipeline[In, Out]{stages: []stage{erase(first)}}
+}
+
+// Then добавляет стадию next в конец конвейера p, исходный p не меняется.
+func Then[In, Mid, Out any](p *Pipeline[In, Mid], next Stage[Mid, Out]) *Pipeline[In, Out] {
+	stages := make([]stage, 0, len(p.stages)+1)
+	stages = append(stages, p.stages...)
+	return &Pipeline[In, Out]{stages: append(stages, erase(next))}
+}
+
+// Stage представляет весь конвейер одной стадией, чтобы вкладывать его в другие конвейеры.
+func (p *Pipeline[In, Out]) Stage() Stage[In, Out] {
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		return bridge(ctx, in, out, func(val In) (interface{}, error) { return val, nil }, assertOut[Out],
+			func(in, out chan interface{}) error {
+				return runStages(ctx, in, out, p.stages)
+			})
+	}
+}
+
+// Run прогоняет inputs через конвейер и возвращает все результаты в порядке их получения.
+func (p *Pipeline[In, Out]) Run(ctx context.Context, inputs ...In) ([]Out, error) {
+	in := make(chan interface{}, len(inputs))
+	for _, val := range inputs {
+		in <- val
+	}
+	close(in)
+
+	out := make(chan interface{})
+	collected := make(chan []Out)
+	go func() {
+		var results []Out
+		for val := range out {
+			results = append(results, val.(Out))
+		}
+		collected <- results
+	}()
+
+	err := runStages(ctx, in, out, p.stages)
+	close(out)
+	results := <-collected
+
+	return results, err
+}
+
+// assertOut приводит выход последней стадии, тип которого гарантирован Then.
+func assertOut[T any](val interface{}) T {
+	return val.(T)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache — ограниченный по размеру LRU-кеш подписей с временем жизни записей.
+// Одновременные запросы одного ключа ждут один общий вызов, ошибки не кешируются.
+// Отмена ctx одного запроса не обрывает общий вызов для остальных.
+// Ключом служат сами данные, поэтому на каждый Signer нужен свой Cache.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List // от недавно использованных к давно использованным
+	calls map[string]*cacheCall
+	stats CacheStats
+}
+
+// CacheStats — счётчики обращений к Cache.
+type CacheStats struct {
+	Hits   uint64 // результат взят из кеша
+	Shared uint64 // запрос дождался чужого вызова с тем же ключом
+	Misses uint64 // пришлось вызвать Signer
+	Len    int
+}
+
+type cacheEntry struct {
+	key     string
+	val     string
+	expires time.Time
+}
+
+type cacheCall struct {
+	done    chan struct{}
+	val     string
+	err     error
+	waiters int                // сколько запросов ещё ждут вызов
+	cancel  context.CancelFunc // отменяет вызов, когда ждать его больше некому
+}
+
+// NewCache создаёт кеш на size записей. При ttl <= 0 записи не устаревают.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+		calls: make(map[string]*cacheCall),
+	}
+}
+
+// Do возвращает значение по key из кеша, а при промахе вызывает fn и запоминает результат.
+// Общий вызов не привязан к ctx ни одного из запросов: каждый запрос перестаёт ждать по своему ctx,
+// а fn получает ctx без отмены и отменяется, только когда ушли все ждавшие.
+func (c *Cache) Do(ctx context.Context, key string, fn func(ctx context.Context) (string, error)) (string, error) {
+	c.mu.Lock()
+	if val, ok := c.getLocked(key); ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		return val, nil
+	}
+	call, ok := c.calls[key]
+	if ok {
+		c.stats.Shared++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &cacheCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		c.stats.Misses++
+		go c.run(callCtx, key, call, fn)
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+		return "", ctx.Err()
+	}
+}
+
+func (c *Cache) run(ctx context.Context, key string, call *cacheCall, fn func(ctx context.Context) (string, error)) {
+	call.val, call.err = fn(ctx)
+	call.cancel()
+
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	if call.err == nil {
+		c.setLocked(key, call.val)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// Stats возвращает счётчики кеша.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.order.Len()
+	return stats
+}
+
+func (c *Cache) getLocked(key string) (string, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.val, true
+}
+
+func (c *Cache) setLocked(key, val string) {
+	if c.size <= 0 {
+		return
+	}
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.val, entry.expires = val, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, val: val, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// CachedSigner запоминает результаты s в c.
+func CachedSigner(s Signer, c *Cache) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return c.Do(ctx, data, func(ctx context.Context) (string, error) {
+			return s.Sign(ctx, data)
+		})
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSingleflight(t *testing.T) {
+	var calls uint32
+	slow := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		atomic.AddUint32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "signed " + data, nil
+	})
+	c := NewCache(10, time.Minute)
+	s := CachedSigner(slow, c)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, err := s.Sign(context.Background(), "data"); err != nil || res != "signed data" {
+				t.Errorf("unexpected result %q, %v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := s.Sign(context.Background(), "data"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("signer called %d times, expected once", calls)
+	}
+	if stats := c.Stats(); stats.Misses != 1 || stats.Hits+stats.Shared != 10 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheLeaderCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	slow := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "signed " + data, nil
+		case <-ctx.Done():
+			close(cancelled)
+			return "", ctx.Err()
+		}
+	})
+	c := NewCache(10, time.Minute)
+	s := CachedSigner(slow, c)
+
+	// первый запрос запускает вызов и уходит по своему таймауту, второй получает результат
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := s.Sign(leaderCtx, "data")
+		leaderErr <- err
+	}()
+	<-started
+
+	res := make(chan string, 1)
+	go func() {
+		val, err := s.Sign(context.Background(), "data")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		res <- val
+	}()
+	for c.Stats().Shared != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("leader must get its own error\nGot: %v\nExpected: %v", err, context.Canceled)
+	}
+	close(release)
+	if val := <-res; val != "signed data" {
+		t.Errorf("waiter got leader's cancel\nGot: %q\nExpected: %q", val, "signed data")
+	}
+
+	// когда ушли все ждавшие, вызов отменяется
+	c = NewCache(10, time.Minute)
+	started, release, cancelled = make(chan struct{}), make(chan struct{}), make(chan struct{})
+	s = CachedSigner(slow, c)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := s.Sign(ctx, "data"); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("abandoned call was not cancelled")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	var calls uint32
+	counting := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		atomic.AddUint32(&calls, 1)
+		return data, nil
+	})
+	c := NewCache(2, 30*time.Millisecond)
+	s := CachedSigner(counting, c)
+	ctx := context.Background()
+
+	for _, data := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, _ = s.Sign(ctx, data)
+	}
+	// a, b промахи; a попадание; c вытесняет b; a попадание; b снова промах
+	if calls != 4 {
+		t.Errorf("LRU eviction\nGot: %d calls\nExpected: 4", calls)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	_, _ = s.Sign(ctx, "a")
+	if calls != 5 {
+		t.Errorf("TTL expiration\nGot: %d calls\nExpected: 5", calls)
+	}
+}
+
+func TestHasherWithCache(t *testing.T) {
+	var calls uint32
+	crc := NewCRC32Signer("")
+	counting := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		atomic.AddUint32(&calls, 1)
+		return crc.Sign(ctx, data)
+	})
+	h := NewHasher(WithChecksum(counting), WithDigest(NewMD5Signer("")), WithCache(100, time.Minute))
+
+	first, err := h.Pipeline().Run(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := h.Pipeline().Run(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if first[0] != second[0] {
+		t.Errorf("cached result differs\nGot: %v\nExpected: %v", second[0], first[0])
+	}
+	if calls != 2*8 {
+		t.Errorf("repeated run was not cached: %d checksum calls", calls)
+	}
+	if checksum, _ := h.CacheStats(); checksum.Hits != 2*8 {
+		t.Errorf("unexpected stats: %+v", checksum)
+	}
+}
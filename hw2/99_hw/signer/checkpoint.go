@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Checkpoint — журнал готовых результатов стадий в файле, по записи JSON на строку.
+// Файл только дописывается; при повторном запуске стадия с WithCheckpoint не считает заново
+// значения из журнала, а сразу отдаёт сохранённый результат.
+type Checkpoint struct {
+	mu   sync.Mutex
+	file *os.File
+	done map[string]json.RawMessage
+	// отпечаток схемы, с которой стадия начала писать журнал, см. stageConfig.fingerprint
+	fingerprints map[string]string
+}
+
+type checkpointRecord struct {
+	Stage       string          `json:"stage"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Input       json.RawMessage `json:"input"`
+	Output      json.RawMessage `json:"output"`
+}
+
+// OpenCheckpoint читает журнал path (или создаёт новый) и открывает его на дозапись.
+// Недописанная последняя строка, оставшаяся после аварийной остановки, отбрасывается.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open checkpoint: %w", err)
+	}
+
+	cp := &Checkpoint{file: file, done: make(map[string]json.RawMessage), fingerprints: make(map[string]string)}
+	if err := cp.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (cp *Checkpoint) load() error {
+	reader := bufio.NewReader(cp.file)
+	var offset int64
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// всё после последнего перевода строки — недописанная запись
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read checkpoint: %w", err)
+		}
+
+		record := checkpointRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("checkpoint line %d: %w", lineNum, err)
+		}
+		if fp, ok := cp.fingerprints[record.Stage]; ok && fp != record.Fingerprint {
+			return fmt.Errorf("checkpoint line %d: stage %s was recorded with different schemes", lineNum, record.Stage)
+		}
+		cp.fingerprints[record.Stage] = record.Fingerprint
+		cp.done[checkpointKey(record.Stage, record.Input)] = record.Output
+		offset += int64(len(line))
+	}
+
+	if err := cp.file.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate checkpoint: %w", err)
+	}
+	if _, err := cp.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Len возвращает число сохранённых результатов.
+func (cp *Checkpoint) Len() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return len(cp.done)
+}
+
+func (cp *Checkpoint) Close() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.file.Close()
+}
+
+// lookup ищет готовый результат. Если стадия уже писала журнал с другим отпечатком схемы,
+// старые результаты не подходят, и возвращается ошибка.
+func (cp *Checkpoint) lookup(stage, fingerprint string, input json.RawMessage) (json.RawMessage, bool, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if fp, ok := cp.fingerprints[stage]; ok && fp != fingerprint {
+		return nil, false, fmt.Errorf("stage %s was recorded with a different hasher configuration", stage)
+	}
+	output, ok := cp.done[checkpointKey(stage, input)]
+	return output, ok, nil
+}
+
+func (cp *Checkpoint) record(stage, fingerprint string, input, output json.RawMessage) error {
+	line, err := json.Marshal(checkpointRecord{Stage: stage, Fingerprint: fingerprint, Input: input, Output: output})
+	if err != nil {
+		return err
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if fp, ok := cp.fingerprints[stage]; ok && fp != fingerprint {
+		return fmt.Errorf("stage %s was recorded with a different hasher configuration", stage)
+	}
+	// одна запись — один Write, чтобы при остановке на диске оставалась максимум одна неполная строка
+	if _, err := cp.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	cp.fingerprints[stage] = fingerprint
+	cp.done[checkpointKey(stage, input)] = output
+	return nil
+}
+
+func checkpointKey(stage string, input json.RawMessage) string {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, input); err != nil {
+		return stage + "\x00" + string(input)
+	}
+	return stage + "\x00" + buf.String()
+}
+
+// checkpointed отдаёт результат fn из cp, если он там есть, а новый результат записывает в cp.
+// fingerprint (может быть nil) отличает журналы, записанные по другой схеме.
+func checkpointed[In, Out any](cp *Checkpoint, stage string, fingerprint func(ctx context.Context) (string, error),
+	fn func(ctx context.Context, val In) (Out, error)) func(ctx context.Context, val In) (Out, error) {
+	return func(ctx context.Context, val In) (Out, error) {
+		var res Out
+		input, err := json.Marshal(val)
+		if err != nil {
+			return res, ItemError(val, fmt.Errorf("checkpoint: %w", err))
+		}
+		var fp string
+		if fingerprint != nil {
+			if fp, err = fingerprint(ctx); err != nil {
+				return res, ItemError(val, fmt.Errorf("checkpoint: fingerprint: %w", err))
+			}
+		}
+		output, ok, err := cp.lookup(stage, fp, input)
+		if err != nil {
+			return res, ItemError(val, fmt.Errorf("checkpoint: %w", err))
+		}
+		if ok {
+			if err := json.Unmarshal(output, &res); err == nil {
+				return res, nil
+			}
+		}
+
+		res, err = fn(ctx, val)
+		if err != nil {
+			return res, err
+		}
+
+		output, err = json.Marshal(res)
+		if err == nil {
+			err = cp.record(stage, fp, input, output)
+		}
+		if err != nil {
+			return res, ItemError(val, fmt.Errorf("checkpoint: %w", err))
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+
+	var calls uint32
+	crc := NewCRC32Signer("")
+	counting := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		atomic.AddUint32(&calls, 1)
+		return crc.Sign(ctx, data)
+	})
+	run := func(inputs ...int) string {
+		cp, err := OpenCheckpoint(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cp.Close()
+
+		h := NewHasher(WithChecksum(counting), WithDigest(NewMD5Signer("")),
+			WithStageOptions(WithCheckpoint(cp)))
+		results, err := h.Pipeline().Run(context.Background(), inputs...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return results[0]
+	}
+
+	// ещё один вызов checksum уходит на отпечаток схемы
+	run(0, 1, 2)
+	if calls != 3*8+1 {
+		t.Fatalf("unexpected checksum calls on first run: %d", calls)
+	}
+
+	// имитируем обрыв записи посреди строки
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"stage":"SingleHash","inp`)
+	file.Close()
+
+	calls = 0
+	resumed := run(0, 1, 2, 3)
+	if calls != 8+1 {
+		t.Errorf("processed inputs were not skipped: %d checksum calls, expected 9", calls)
+	}
+
+	fresh, err := NewHasher(WithChecksum(crc), WithDigest(NewMD5Signer(""))).Pipeline().Run(context.Background(), 0, 1, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resumed != fresh[0] {
+		t.Errorf("resumed result differs\nGot: %v\nExpected: %v", resumed, fresh[0])
+	}
+}
+
+func TestCheckpointSchemeMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	run := func(opts ...HasherOption) error {
+		cp, err := OpenCheckpoint(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cp.Close()
+
+		opts = append([]HasherOption{WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")),
+			WithStageOptions(WithCheckpoint(cp))}, opts...)
+		_, err = NewHasher(opts...).Pipeline().Run(context.Background(), 0, 1)
+		return err
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := run(); err != nil {
+		t.Errorf("same scheme rejected: %v", err)
+	}
+
+	changed := [][]HasherOption{
+		{WithChecksum(NewCRC32Signer("salt"))},
+		{WithDigest(NewSHA256Signer(""))},
+		{WithRounds(3)},
+		{WithSingleSeparator("-")},
+	}
+	for caseNum, opts := range changed {
+		err := run(opts...)
+		if err == nil || !strings.Contains(err.Error(), "different hasher configuration") {
+			t.Errorf("case %d: expected scheme mismatch, got %v", caseNum, err)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const cliUsage = `signer считает подписи SingleHash -> MultiHash -> CombineResults для входных значений.
+
+Значения берутся из аргументов, из файла -in или со стандартного входа, по одному на строку.
+
+Пример:
+	seq 0 9 | signer -stages single,multi -format jsonl
+	signer -in inputs.txt -salt secret -workers 16 -timeout 30s
+	signer -serve 127.0.0.1:9001 &
+	signer -remote 127.0.0.1:9001,127.0.0.1:9002 -in inputs.txt
+	signer -bench -bench-sizes 10,100,1000 -bench-workers 0,4,16 -bench-scale 100
+
+Флаги:
+`
+
+// cliStages — стадии, которые можно выбрать флагом -stages, в порядке конвейера.
+var cliStages = []string{"single", "multi", "combine"}
+
+type cliConfig struct {
+	inPath    string
+	stages    []string
+	format    string
+	salt      string
+	checksum  string
+	workers   int
+	timeout   time.Duration
+	verbose   bool
+	serve     string
+	remote    []string
+	retries   int
+	bench     bool
+	benchCfg  BenchConfig
+	arguments []string
+}
+
+// runCLI разбирает аргументы командной строки и прогоняет значения через выбранные стадии.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cfg, err := parseCLI(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	if cfg.bench {
+		results, err := Bench(context.Background(), cfg.benchCfg)
+		if err != nil {
+			return err
+		}
+		return WriteBenchTable(stdout, results)
+	}
+
+	input := stdin
+	if len(cfg.arguments) > 0 {
+		input = strings.NewReader(strings.Join(cfg.arguments, "\n"))
+	} else if cfg.inPath != "-" {
+		file, err := os.Open(cfg.inPath)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	checksum, err := newCLISigner(cfg.checksum, cfg.salt)
+	if err != nil {
+		return err
+	}
+	opts := []HasherOption{WithChecksum(checksum), WithDigest(NewMD5Signer(cfg.salt))}
+	if cfg.verbose {
+		opts = append(opts, WithLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	}
+	h := NewHasher(opts...)
+
+	if cfg.serve != "" {
+		return serveStages(h, cfg.serve, stderr)
+	}
+
+	ctx := context.Background()
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	var workers *Workers
+	if len(cfg.remote) > 0 {
+		if workers, err = DialWorkers(cfg.remote, cfg.retries); err != nil {
+			return err
+		}
+		defer workers.Close()
+	}
+
+	return signStream(ctx, h, workers, cfg, input, stdout)
+}
+
+// serveStages отдаёт SingleHash и MultiHash хешера h по gRPC, пока не упадёт сервер.
+func serveStages(h *Hasher, addr string, stderr io.Writer) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	srv := NewStageServer()
+	RegisterStage(srv, "SingleHash", h.SingleHashStage())
+	RegisterStage(srv, "MultiHash", h.MultiHashStage())
+
+	g := grpc.NewServer()
+	srv.Register(g)
+	fmt.Fprintln(stderr, "serving stages at", lis.Addr())
+	return g.Serve(lis)
+}
+
+func parseCLI(args []string, stderr io.Writer) (cliConfig, error) {
+	cfg := cliConfig{}
+	var stages, remote, benchSizes, benchWorkers string
+
+	flags := flag.NewFlagSet("signer", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, cliUsage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&cfg.inPath, "in", "-", "файл со значениями, - для стандартного входа")
+	flags.StringVar(&stages, "stages", strings.Join(cliStages, ","), "стадии через запятую: single, multi, combine")
+	flags.StringVar(&cfg.format, "format", "text", "формат вывода: text, jsonl или csv")
+	flags.StringVar(&cfg.salt, "salt", "", "соль для подписей")
+	flags.StringVar(&cfg.checksum, "checksum", "crc32", "подпись вместо crc32: crc32, sha256, xxhash или hmac (ключ — соль)")
+	flags.IntVar(&cfg.workers, "workers", 0, "сколько значений обрабатывать одновременно на стадии, 0 — без ограничения")
+	flags.DurationVar(&cfg.timeout, "timeout", 0, "ограничение на весь прогон, 0 — без ограничения")
+	flags.BoolVar(&cfg.verbose, "v", false, "писать подробный лог в stderr")
+	flags.StringVar(&cfg.serve, "serve", "", "не считать самому, а отдавать SingleHash и MultiHash по gRPC на этом адресе")
+	flags.StringVar(&remote, "remote", "", "адреса воркеров через запятую, на которых считать SingleHash и MultiHash")
+	flags.IntVar(&cfg.retries, "retries", 2, "сколько раз повторять вызов воркера на других адресах")
+	flags.BoolVar(&cfg.bench, "bench", false, "не считать подписи, а замерить скорость SingleHash и MultiHash")
+	flags.StringVar(&benchSizes, "bench-sizes", "10,100,1000", "размеры входа для -bench через запятую")
+	flags.StringVar(&benchWorkers, "bench-workers", "0,4,16", "значения -workers для -bench через запятую")
+	flags.Float64Var(&cfg.benchCfg.Scale, "bench-scale", 100, "во сколько раз укоротить паузы подписей для -bench")
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+	cfg.arguments = flags.Args()
+	if remote != "" {
+		cfg.remote = strings.Split(remote, ",")
+	}
+
+	var err error
+	if cfg.stages, err = parseStages(stages); err != nil {
+		return cfg, err
+	}
+	if cfg.benchCfg.Sizes, err = parseInts("bench-sizes", benchSizes); err != nil {
+		return cfg, err
+	}
+	if cfg.benchCfg.Workers, err = parseInts("bench-workers", benchWorkers); err != nil {
+		return cfg, err
+	}
+	switch cfg.format {
+	case "text", "jsonl", "csv":
+	default:
+		return cfg, fmt.Errorf("unknown format %q", cfg.format)
+	}
+	return cfg, nil
+}
+
+// parseStages проверяет, что стадии идут подряд и в порядке конвейера, например "multi,combine".
+func parseStages(list string) ([]string, error) {
+	stages := strings.Split(list, ",")
+	first := -1
+	for i, name := range cliStages {
+		if name == strings.TrimSpace(stages[0]) {
+			first = i
+		}
+	}
+	if first < 0 || first+len(stages) > len(cliStages) {
+		return nil, fmt.Errorf("bad stages %q: expected a subsequence of %s", list, strings.Join(cliStages, ","))
+	}
+	for i, name := range stages {
+		stages[i] = strings.TrimSpace(name)
+		if stages[i] != cliStages[first+i] {
+			return nil, fmt.Errorf("bad stages %q: expected a subsequence of %s", list, strings.Join(cliStages, ","))
+		}
+	}
+	return stages, nil
+}
+
+// parseInts разбирает неотрицательные числа через запятую из флага name.
+func parseInts(name, list string) ([]int, error) {
+	var nums []int
+	for _, field := range strings.Split(list, ",") {
+		num, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || num < 0 {
+			return nil, fmt.Errorf("bad -%s %q: expected non-negative integers", name, list)
+		}
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+func newCLISigner(name, salt string) (Signer, error) {
+	switch name {
+	case "crc32":
+		return NewCRC32Signer(salt), nil
+	case "sha256":
+		return NewSHA256Signer(salt), nil
+	case "xxhash":
+		return NewXXHashSigner(salt), nil
+	case "hmac":
+		return NewHMACSigner([]byte(salt)), nil
+	default:
+		return nil, fmt.Errorf("unknown checksum %q", name)
+	}
+}
+
+// signStream читает значения построчно, прогоняет их через стадии и пишет результаты по мере готовности.
+// Без combine каждый результат соответствует одному входу, поэтому стадии работают в порядке входа,
+// а вход выводится рядом с результатом.
+// Если заданы workers, SingleHash и MultiHash считаются на них.
+func signStream(ctx context.Context, h *Hasher, workers *Workers, cfg cliConfig, input io.Reader, output io.Writer) error {
+	combined := cfg.stages[len(cfg.stages)-1] == "combine"
+
+	stageOpts := []StageOption{WithWorkers(cfg.workers)}
+	if !combined {
+		stageOpts = append(stageOpts, WithOrdered())
+	}
+
+	var (
+		mu      sync.Mutex
+		pending []string // входы, для которых ещё не выведен результат
+	)
+
+	stages := []stage{readLines(input, cfg.stages[0] == "single", func(line string) {
+		mu.Lock()
+		pending = append(pending, line)
+		mu.Unlock()
+	})}
+	for _, name := range cfg.stages {
+		switch name {
+		case "single":
+			if workers != nil {
+				stages = append(stages, erase(RemoteStage[int, string]("SingleHash", workers, stageOpts...)))
+			} else {
+				stages = append(stages, erase(h.SingleHashStage(stageOpts...)))
+			}
+		case "multi":
+			if workers != nil {
+				stages = append(stages, erase(RemoteStage[string, string]("MultiHash", workers, stageOpts...)))
+			} else {
+				stages = append(stages, erase(h.MultiHashStage(stageOpts...)))
+			}
+		case "combine":
+			stages = append(stages, erase(h.CombineResultsStage()))
+		}
+	}
+
+	w := newResultWriter(cfg.format, output, !combined)
+	stages = append(stages, func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		for {
+			// буфер сбрасывается, как только готовых результатов не осталось, чтобы они не ждали конца входа
+			var val interface{}
+			var ok bool
+			select {
+			case val, ok = <-in:
+			default:
+				if err := w.flush(); err != nil {
+					return err
+				}
+				val, ok = <-in
+			}
+			if !ok {
+				break
+			}
+
+			source := ""
+			if !combined {
+				mu.Lock()
+				source, pending = pending[0], pending[1:]
+				mu.Unlock()
+			}
+			if err := w.write(source, val.(string)); err != nil {
+				return err
+			}
+		}
+		return w.flush()
+	})
+
+	return ExecuteStages(ctx, stages...)
+}
+
+// readLines — стадия-источник: непустые строки из r, для SingleHash приведённые к int.
+func readLines(r io.Reader, numbers bool, seen func(line string)) stage {
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		scanner := bufio.NewScanner(r)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var val interface{} = line
+			if numbers {
+				num, err := strconv.Atoi(line)
+				if err != nil {
+					return ItemError(line, fmt.Errorf("line %d: not an integer", lineNum))
+				}
+				val = num
+			}
+
+			seen(line)
+			if err := send(ctx, out, val); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+}
+
+type resultWriter struct {
+	format    string
+	withInput bool
+	w         *bufio.Writer
+	csv       *csv.Writer
+}
+
+func newResultWriter(format string, output io.Writer, withInput bool) *resultWriter {
+	rw := &resultWriter{format: format, withInput: withInput, w: bufio.NewWriter(output)}
+	if format == "csv" {
+		rw.csv = csv.NewWriter(rw.w)
+	}
+	return rw
+}
+
+func (rw *resultWriter) write(input, result string) error {
+	switch rw.format {
+	case "jsonl":
+		record := struct {
+			Input  string `json:"input,omitempty"`
+			Result string `json:"result"`
+		}{input, result}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(rw.w, "%s\n", data)
+		return err
+	case "csv":
+		if rw.withInput {
+			return rw.csv.Write([]string{input, result})
+		}
+		return rw.csv.Write([]string{result})
+	default:
+		if rw.withInput {
+			_, err := fmt.Fprintf(rw.w, "%s\t%s\n", input, result)
+			return err
+		}
+		_, err := fmt.Fprintln(rw.w, result)
+		return err
+	}
+}
+
+func (rw *resultWriter) flush() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return rw.w.Flush()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCLI(t *testing.T) {
+	inPath := filepath.Join(t.TempDir(), "inputs.txt")
+	if err := os.WriteFile(inPath, []byte("0\n\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{
+			args:     []string{},
+			stdin:    "0\n1\n",
+			expected: "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542\n",
+		},
+		{
+			args:     []string{"-in", inPath, "-stages", "single", "-format", "jsonl"},
+			expected: `{"input":"0","result":"4108050209~502633748"}` + "\n" + `{"input":"1","result":"2212294583~709660146"}` + "\n",
+		},
+		{
+			args:     []string{"-stages", "single,multi", "-format", "csv", "-workers", "1", "1"},
+			expected: "1,4958044192186797981418233587017209679042592862002427381542\n",
+		},
+	}
+
+	for caseNum, item := range cases {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := runCLI(item.args, strings.NewReader(item.stdin), stdout, stderr)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		if stdout.String() != item.expected {
+			t.Errorf("case %d: results not match\nGot: %v\nExpected: %v", caseNum, stdout.String(), item.expected)
+		}
+	}
+}
+
+// chanWriter отдаёт в канал всё, что в него пишут.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestCLIStreamsResults(t *testing.T) {
+	stdin, input := io.Pipe()
+	stdout := make(chanWriter, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- runCLI([]string{"-stages", "single"}, stdin, stdout, &bytes.Buffer{})
+	}()
+
+	// результат должен прийти, пока вход ещё открыт
+	input.Write([]byte("0\n"))
+	select {
+	case got := <-stdout:
+		if got != "0\t4108050209~502633748\n" {
+			t.Errorf("wrong result\nGot: %q\nExpected: %q", got, "0\t4108050209~502633748\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("result held back until input ends")
+	}
+
+	input.Close()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCLIErrors(t *testing.T) {
+	cases := [][]string{
+		{"-stages", "single,combine"},
+		{"-format", "xml"},
+		{"-checksum", "sha1"},
+		{"not-a-number"},
+		{"-bench", "-bench-sizes", "ten"},
+	}
+	for caseNum, args := range cases {
+		err := runCLI(args, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("case %d: expected error for %v", caseNum, args)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// StreamCombineResultsStage — CombineResults, который не ждёт конца входа, а выдаёт промежуточные
+// результаты по всем пришедшим значениям: каждые n значений (WithSnapshotEvery) и/или по таймеру
+// (WithSnapshotInterval). Последнее выданное значение — итоговый результат, как у CombineResultsStage.
+func (h *Hasher) StreamCombineResultsStage(opts ...StageOption) Stage[string, string] {
+	cfg := h.stageConfig("CombineResults", opts)
+	return func(ctx context.Context, in <-chan string, out chan<- string) error {
+		c := h.newCombiner(cfg)
+		defer c.close()
+
+		var tick <-chan time.Time
+		if cfg.snapshotInterval > 0 {
+			ticker := time.NewTicker(cfg.snapshotInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		emitted, fresh := false, false
+		emit := func() error {
+			res, err := c.result()
+			if err != nil {
+				return err
+			}
+			emitted, fresh = true, false
+			cfg.logger.Debug("snapshot", "stage", cfg.name, "items", c.count)
+			return send(ctx, out, res)
+		}
+
+		for {
+			select {
+			case val, ok := <-in:
+				if !ok {
+					cfg.logger.Info("stage finished", "stage", cfg.name, "items", c.count)
+					if fresh || !emitted {
+						return emit()
+					}
+					return nil
+				}
+				if err := c.add(val); err != nil {
+					return err
+				}
+				fresh = true
+				if cfg.snapshotEvery > 0 && c.count%cfg.snapshotEvery == 0 {
+					if err := emit(); err != nil {
+						return err
+					}
+				}
+			case <-tick:
+				if fresh {
+					if err := emit(); err != nil {
+						return err
+					}
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// combiner копит результаты для CombineResults и при заданном cfg.spillAt сбрасывает
+// отсортированные куски во временные файлы, по одному результату на строку.
+type combiner struct {
+	order   SortOrder
+	sep     string
+	spillAt int
+	dir     string
+	logger  *slog.Logger
+
+	buf   []string
+	runs  []string // файлы с отсортированными кусками, в порядке записи
+	count int
+}
+
+func (h *Hasher) newCombiner(cfg stageConfig) *combiner {
+	return &combiner{order: h.sortOrder, sep: h.combineSep, spillAt: cfg.spillAt, dir: cfg.spillDir, logger: cfg.logger}
+}
+
+func (c *combiner) add(val string) error {
+	c.buf = append(c.buf, val)
+	c.count++
+	if c.spillAt > 0 && len(c.buf) >= c.spillAt {
+		return c.spill()
+	}
+	return nil
+}
+
+func (c *combiner) spill() error {
+	sortResults(c.buf, c.order)
+	file, err := os.CreateTemp(c.dir, "combine-*.txt")
+	if err != nil {
+		return fmt.Errorf("spill results: %w", err)
+	}
+	c.runs = append(c.runs, file.Name())
+
+	w := bufio.NewWriter(file)
+	for _, val := range c.buf {
+		if strings.ContainsRune(val, '\n') {
+			file.Close()
+			return ItemError(val, fmt.Errorf("spill results: value contains a newline"))
+		}
+		w.WriteString(val)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("spill results: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("spill results: %w", err)
+	}
+	c.logger.Debug("results spilled", "file", file.Name(), "items", len(c.buf))
+	c.buf = c.buf[:0]
+	return nil
+}
+
+// result склеивает все накопленные результаты в строку для снимка StreamCombineResultsStage.
+// При сбросе в файлы слитые куски заодно переписываются в один файл, поэтому следующий снимок
+// читает один файл и новые значения, а число файлов не растёт.
+func (c *combiner) result() (string, error) {
+	sb := strings.Builder{}
+	if err := c.writeTo(&sb, c.spillAt > 0); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// writeTo пишет склейку всех накопленных результатов в w, не собирая её в памяти: куски из файлов
+// читаются построчно и сливаются с отсортированным буфером. С compact слитые значения
+// записываются в новый файл, который заменяет все прежние куски и буфер.
+func (c *combiner) writeTo(w io.Writer, compact bool) (err error) {
+	sortResults(c.buf, c.order)
+	bw := bufio.NewWriter(w)
+	var merged *os.File
+	var mergedW *bufio.Writer
+	if compact && len(c.runs) > 0 {
+		if merged, err = os.CreateTemp(c.dir, "combine-*.txt"); err != nil {
+			return fmt.Errorf("merge results: %w", err)
+		}
+		mergedW = bufio.NewWriter(merged)
+		defer func() {
+			if err != nil {
+				merged.Close()
+				os.Remove(merged.Name())
+			}
+		}()
+	}
+
+	first := true
+	write := func(val string) {
+		if !first {
+			bw.WriteString(c.sep)
+		}
+		first = false
+		bw.WriteString(val)
+		if mergedW != nil {
+			mergedW.WriteString(val)
+			mergedW.WriteByte('\n')
+		}
+	}
+
+	readers := make([]*bufio.Reader, 0, len(c.runs))
+	for _, path := range c.runs {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("merge results: %w", err)
+		}
+		defer file.Close()
+		readers = append(readers, bufio.NewReader(file))
+	}
+
+	if err := c.merge(readers, write); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("merge results: %w", err)
+	}
+	if merged == nil {
+		return nil
+	}
+
+	if err := mergedW.Flush(); err != nil {
+		return fmt.Errorf("merge results: %w", err)
+	}
+	if err := merged.Close(); err != nil {
+		return fmt.Errorf("merge results: %w", err)
+	}
+	for _, path := range c.runs {
+		os.Remove(path)
+	}
+	c.runs = []string{merged.Name()}
+	c.buf = c.buf[:0]
+	return nil
+}
+
+// merge передаёт write значения всех кусков и буфера в порядке c.order.
+func (c *combiner) merge(readers []*bufio.Reader, write func(val string)) error {
+	if c.order == SortNone {
+		for _, r := range readers {
+			for {
+				val, err := readLine(r)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("merge results: %w", err)
+				}
+				write(val)
+			}
+		}
+		for _, val := range c.buf {
+			write(val)
+		}
+		return nil
+	}
+
+	m := &mergeHeap{less: func(a, b string) bool { return a < b }}
+	if c.order == SortNumeric {
+		m.less = lessNumeric
+	}
+	for i, r := range readers {
+		if err := m.pushFrom(i, r); err != nil {
+			return err
+		}
+	}
+	bufPos := 0
+	for m.Len() > 0 || bufPos < len(c.buf) {
+		// буфер пришёл позже всех файлов, поэтому при равенстве уступает им
+		if m.Len() == 0 || (bufPos < len(c.buf) && m.less(c.buf[bufPos], m.items[0].val)) {
+			write(c.buf[bufPos])
+			bufPos++
+			continue
+		}
+		item := heap.Pop(m).(mergeItem)
+		write(item.val)
+		if err := m.pushFrom(item.run, readers[item.run]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkWriter отправляет записанное в out кусками не больше size байт, см. WithChunkSize.
+type chunkWriter struct {
+	ctx  context.Context
+	out  chan<- string
+	size int
+	buf  []byte
+	sent bool
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := cw.size - len(cw.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		cw.buf = append(cw.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(cw.buf) == cw.size {
+			if err := cw.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush отправляет остаток; пустая склейка всё равно даёт одно значение "".
+func (cw *chunkWriter) flush() error {
+	if len(cw.buf) == 0 && cw.sent {
+		return nil
+	}
+	err := send(cw.ctx, cw.out, string(cw.buf))
+	cw.buf = cw.buf[:0]
+	cw.sent = true
+	return err
+}
+
+func (c *combiner) close() {
+	for _, path := range c.runs {
+		os.Remove(path)
+	}
+	c.runs = nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+type mergeItem struct {
+	val string
+	run int
+}
+
+// mergeHeap — куча текущих значений отсортированных кусков. При равенстве первым идёт
+// более ранний кусок, поэтому слияние устойчиво, как sortResults.
+type mergeHeap struct {
+	items []mergeItem
+	less  func(a, b string) bool
+}
+
+func (m *mergeHeap) Len() int { return len(m.items) }
+
+func (m *mergeHeap) Less(i, j int) bool {
+	a, b := m.items[i], m.items[j]
+	if m.less(a.val, b.val) {
+		return true
+	}
+	return !m.less(b.val, a.val) && a.run < b.run
+}
+
+func (m *mergeHeap) Swap(i, j int) { m.items[i], m.items[j] = m.items[j], m.items[i] }
+
+func (m *mergeHeap) Push(x interface{}) { m.items = append(m.items, x.(mergeItem)) }
+
+func (m *mergeHeap) Pop() interface{} {
+	last := m.items[len(m.items)-1]
+	m.items = m.items[:len(m.items)-1]
+	return last
+}
+
+// pushFrom кладёт в кучу следующее значение куска run, если оно есть.
+func (m *mergeHeap) pushFrom(run int, r *bufio.Reader) error {
+	val, err := readLine(r)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("merge results: %w", err)
+	}
+	heap.Push(m, mergeItem{val: val, run: run})
+	return nil
+}
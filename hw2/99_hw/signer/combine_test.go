@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCombineResultsSpill(t *testing.T) {
+	var inputs []string
+	for i := 0; i < 20; i++ {
+		inputs = append(inputs, strconv.Itoa((i*7)%13*(i%3+1)))
+	}
+
+	for _, order := range []SortOrder{SortLexical, SortNumeric, SortNone} {
+		dir := t.TempDir()
+		h := NewHasher(WithSortOrder(order))
+		expected, err := NewPipeline(h.CombineResultsStage()).Run(context.Background(), inputs...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res, err := NewPipeline(h.CombineResultsStage(WithSpill(3, dir))).Run(context.Background(), inputs...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res[0] != expected[0] {
+			t.Errorf("spilled result differs for order %d\nGot: %v\nExpected: %v", order, res[0], expected[0])
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("temp files left: %d", len(files))
+		}
+	}
+}
+
+func TestStreamCombineEvery(t *testing.T) {
+	h := NewHasher(WithSortOrder(SortNumeric))
+	inputs := []string{"5", "3", "4", "1", "2"}
+	res, err := NewPipeline(h.StreamCombineResultsStage(WithSnapshotEvery(2), WithSpill(2, t.TempDir()))).
+		Run(context.Background(), inputs...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"3_5", "1_3_4_5", "1_2_3_4_5"}
+	if strings.Join(res, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong snapshots\nGot: %v\nExpected: %v", res, expected)
+	}
+
+	res, err = NewPipeline(h.StreamCombineResultsStage(WithSnapshotEvery(1))).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 1 || res[0] != "" {
+		t.Errorf("empty input must give one empty result\nGot: %q", res)
+	}
+}
+
+func TestStreamCombineInterval(t *testing.T) {
+	h := NewHasher()
+	in := make(chan string)
+	out := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- h.StreamCombineResultsStage(WithSnapshotInterval(10*time.Millisecond))(context.Background(), in, out)
+		close(out)
+	}()
+
+	more := make(chan struct{})
+	go func() {
+		in <- "b"
+		in <- "a"
+		<-more
+		in <- "c"
+		close(in)
+	}()
+
+	// до закрытия входа должен прийти снимок по обоим значениям
+	timeout := time.After(time.Second)
+	for snapshot := ""; snapshot != "a_b"; {
+		select {
+		case snapshot = <-out:
+		case <-timeout:
+			t.Fatalf("no snapshot before input closed")
+		}
+	}
+	close(more)
+
+	var last string
+	for val := range out {
+		last = val
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if last != "a_b_c" {
+		t.Errorf("wrong final result\nGot: %v\nExpected: %v", last, "a_b_c")
+	}
+}
+
+func TestCombineResultsChunks(t *testing.T) {
+	var inputs []string
+	for i := 0; i < 50; i++ {
+		inputs = append(inputs, strconv.Itoa((i*37)%101))
+	}
+
+	for _, order := range []SortOrder{SortLexical, SortNumeric, SortNone} {
+		h := NewHasher(WithSortOrder(order))
+		expected, err := NewPipeline(h.CombineResultsStage()).Run(context.Background(), inputs...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res, err := NewPipeline(h.CombineResultsStage(WithSpill(4, t.TempDir()), WithChunkSize(7))).
+			Run(context.Background(), inputs...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, chunk := range res {
+			if len(chunk) > 7 {
+				t.Errorf("chunk too long: %q", chunk)
+			}
+		}
+		if strings.Join(res, "") != expected[0] {
+			t.Errorf("chunked result differs for order %d\nGot: %v\nExpected: %v", order, strings.Join(res, ""), expected[0])
+		}
+	}
+
+	h := NewHasher()
+	res, err := NewPipeline(h.CombineResultsStage(WithChunkSize(7))).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 1 || res[0] != "" {
+		t.Errorf("empty input must give one empty result\nGot: %q", res)
+	}
+}
+
+func TestStreamCombineCompactsRuns(t *testing.T) {
+	h := NewHasher(WithSortOrder(SortNumeric))
+	cfg := h.stageConfig("CombineResults", []StageOption{WithSpill(2, t.TempDir())})
+	c := h.newCombiner(cfg)
+	defer c.close()
+
+	for i, val := range []string{"9", "7", "8", "1", "5", "3", "2"} {
+		if err := c.add(val); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if i%3 != 2 {
+			continue
+		}
+		if _, err := c.result(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(c.runs) != 1 || len(c.buf) != 0 {
+			t.Errorf("snapshot must leave one run\nGot: %d runs, %d buffered", len(c.runs), len(c.buf))
+		}
+	}
+	res, err := c.result()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != "1_2_3_5_7_8_9" {
+		t.Errorf("wrong result\nGot: %v\nExpected: %v", res, "1_2_3_5_7_8_9")
+	}
+	if files, _ := os.ReadDir(cfg.spillDir); len(files) != 1 {
+		t.Errorf("stale run files: %d", len(files))
+	}
+}
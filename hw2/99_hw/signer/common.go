@@ -1,11 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"hash/crc32"
 	"strconv"
-	"sync/atomic"
 	"time"
 )
 
@@ -16,30 +16,22 @@ const (
 )
 
 var (
-	dataSignerOverheat uint32 = 0
+	dataSignerOverheat uint32 = 0 // нужен тестам, которые подменяют OverheatLock на свою версию
 	DataSignerSalt            = ""
 )
 
+// overheatLimiter пускает к DataSignerMd5 строго по одному и в порядке очереди,
+// вместо того чтобы спать по секунде при каждом столкновении.
+var overheatLimiter = NewLimiter(WithMaxInFlight(1))
+
 var OverheatLock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1); !swapped {
-			fmt.Println("OverheatLock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
+	if waited, _ := overheatLimiter.Acquire(context.Background()); waited > 0 {
+		currentLogger().Warn("md5 overheat lock", "waited", waited)
 	}
 }
 
 var OverheatUnlock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 1, 0); !swapped {
-			fmt.Println("OverheatUnlock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
+	overheatLimiter.Release()
 }
 
 var DataSignerMd5 = func(data string) string {
@@ -1,3 +1,16 @@
 module hw
 
-go 1.17
+go 1.21
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	google.golang.org/grpc v1.67.1
+)
+
+require (
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
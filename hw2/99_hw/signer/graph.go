@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FanOut задаёт, как узел графа раздаёт результаты по исходящим рёбрам.
+type FanOut int
+
+const (
+	Broadcast  FanOut = iota // каждое значение уходит во все подходящие рёбра
+	RoundRobin               // значения по очереди уходят в подходящие рёбра
+)
+
+// Graph — конвейер в виде ациклического графа стадий. Узел может раздавать результаты
+// нескольким узлам (FanOut), собирать входы с нескольких узлов и направлять значения по условию (Route).
+// Входные значения получают все узлы без входящих рёбер, результаты собираются с узлов без исходящих.
+// Ошибки построения накапливаются и возвращаются из Validate и Run.
+type Graph struct {
+	nodes []*graphNode
+	index map[string]*graphNode
+	err   error
+}
+
+type graphNode struct {
+	id     int
+	name   string
+	fn     stage
+	fanOut FanOut
+	edges  []graphEdge
+	inputs int // число входящих рёбер
+}
+
+type graphEdge struct {
+	to   *graphNode
+	pred func(val interface{}) bool // nil — подходит любое значение
+}
+
+// NewGraph создаёт пустой граф.
+func NewGraph() *Graph {
+	return &Graph{index: make(map[string]*graphNode)}
+}
+
+// AddNode добавляет в граф узел name со стадией fn. Значение не того типа на входе
+// останавливает граф с ошибкой, как и в линейном конвейере.
+func AddNode[In, Out any](g *Graph, name string, fn Stage[In, Out]) *Graph {
+	if _, ok := g.index[name]; ok {
+		g.fail(fmt.Errorf("graph: duplicate node %q", name))
+		return g
+	}
+	node := &graphNode{id: len(g.nodes), name: name, fn: erase(fn)}
+	g.nodes = append(g.nodes, node)
+	g.index[name] = node
+	return g
+}
+
+// Connect передаёт все результаты узла from на вход узла to.
+func (g *Graph) Connect(from, to string) *Graph {
+	return g.Route(from, to, nil)
+}
+
+// Route передаёт на вход узла to только те результаты from, для которых pred вернул true.
+// Значение, не подошедшее ни к одному ребру, отбрасывается.
+func (g *Graph) Route(from, to string, pred func(val interface{}) bool) *Graph {
+	src, dst := g.node(from), g.node(to)
+	if src == nil || dst == nil {
+		return g
+	}
+	src.edges = append(src.edges, graphEdge{to: dst, pred: pred})
+	dst.inputs++
+	return g
+}
+
+// SetFanOut задаёт режим раздачи результатов узла name, по умолчанию Broadcast.
+func (g *Graph) SetFanOut(name string, mode FanOut) *Graph {
+	if node := g.node(name); node != nil {
+		node.fanOut = mode
+	}
+	return g
+}
+
+func (g *Graph) node(name string) *graphNode {
+	node, ok := g.index[name]
+	if !ok {
+		g.fail(fmt.Errorf("graph: unknown node %q", name))
+	}
+	return node
+}
+
+func (g *Graph) fail(err error) {
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+// Validate проверяет, что граф собран без ошибок, не пуст и не содержит циклов.
+func (g *Graph) Validate() error {
+	if g.err != nil {
+		return g.err
+	}
+	if len(g.nodes) == 0 {
+		return fmt.Errorf("graph: no nodes")
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.nodes))
+	var path []string
+	var visit func(node *graphNode) error
+	visit = func(node *graphNode) error {
+		switch state[node.id] {
+		case visiting:
+			for i, name := range path {
+				if name == node.name {
+					return fmt.Errorf("graph: cycle %s -> %s", strings.Join(path[i:], " -> "), node.name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[node.id] = visiting
+		path = append(path, node.name)
+		for _, edge := range node.edges {
+			if err := visit(edge.to); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[node.id] = visited
+		return nil
+	}
+	for _, node := range g.nodes {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run проверяет граф, прогоняет через него inputs и возвращает результаты каждого
+// узла без исходящих рёбер. Первая ошибка отменяет ctx у всех узлов и возвращается
+// как *StageError с номером узла в порядке добавления.
+func (g *Graph) Run(ctx context.Context, inputs ...interface{}) (map[string][]interface{}, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		results  = make(map[string][]interface{})
+	)
+	setErr := func(node *graphNode, err error) {
+		mu.Lock()
+		if firstErr == nil && parent.Err() == nil {
+			firstErr = fmt.Errorf("node %q: %w", node.name, withStage(node.id, err))
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	// вход узла закрывается, когда допишут все, кто в него пишет
+	ins := make([]chan interface{}, len(g.nodes))
+	writers := make([]*sync.WaitGroup, len(g.nodes))
+	for i, node := range g.nodes {
+		ins[i] = make(chan interface{})
+		writers[i] = &sync.WaitGroup{}
+		if node.inputs == 0 {
+			writers[i].Add(1)
+		} else {
+			writers[i].Add(node.inputs)
+		}
+		go func(i int) {
+			writers[i].Wait()
+			close(ins[i])
+		}(i)
+	}
+
+	wg := sync.WaitGroup{}
+	for _, node := range g.nodes {
+		if node.inputs == 0 {
+			wg.Add(1)
+			go func(node *graphNode) {
+				defer wg.Done()
+				defer writers[node.id].Done()
+				for _, val := range inputs {
+					if err := send(ctx, ins[node.id], val); err != nil {
+						return
+					}
+				}
+			}(node)
+		}
+
+		out := make(chan interface{})
+		wg.Add(2)
+		go func(node *graphNode) {
+			defer wg.Done()
+			err := node.fn(ctx, ins[node.id], out)
+			close(out)
+			if err != nil {
+				setErr(node, err)
+			}
+		}(node)
+		go func(node *graphNode) {
+			defer wg.Done()
+			defer func() {
+				for _, edge := range node.edges {
+					writers[edge.to.id].Done()
+				}
+			}()
+			if len(node.edges) == 0 {
+				for val := range out {
+					mu.Lock()
+					results[node.name] = append(results[node.name], val)
+					mu.Unlock()
+				}
+				return
+			}
+			if err := dispatch(ctx, node, out, ins); err != nil {
+				go drain(out)
+			}
+		}(node)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return nil, fmt.Errorf("execute graph: %w", err)
+	}
+	return results, nil
+}
+
+// dispatch раздаёт выход узла по его рёбрам в соответствии с режимом FanOut.
+func dispatch(ctx context.Context, node *graphNode, out <-chan interface{}, ins []chan interface{}) error {
+	next := 0
+	for val := range out {
+		for i := range node.edges {
+			edge := node.edges[(next+i)%len(node.edges)]
+			if edge.pred != nil && !edge.pred(val) {
+				continue
+			}
+			if err := send(ctx, ins[edge.to.id], val); err != nil {
+				return err
+			}
+			if node.fanOut == RoundRobin {
+				next = (next + i + 1) % len(node.edges)
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func mapStage(fn func(int) int) Stage[int, int] {
+	return func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			if err := send(ctx, out, fn(val)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func sortedInts(vals []interface{}) []int {
+	res := make([]int, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, val.(int))
+	}
+	sort.Ints(res)
+	return res
+}
+
+func TestGraphDiamond(t *testing.T) {
+	g := NewGraph()
+	AddNode(g, "double", mapStage(func(v int) int { return v * 2 }))
+	AddNode(g, "inc", mapStage(func(v int) int { return v + 1 }))
+	AddNode(g, "scale", mapStage(func(v int) int { return v * 10 }))
+	AddNode(g, "merge", mapStage(func(v int) int { return v }))
+	g.Connect("double", "inc").Connect("double", "scale").
+		Connect("inc", "merge").Connect("scale", "merge")
+
+	res, err := g.Run(context.Background(), 1, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := sortedInts(res["merge"])
+	expected := []int{3, 5, 7, 20, 40, 60}
+	if !equalInts(got, expected) {
+		t.Errorf("wrong results\nGot: %v\nExpected: %v", got, expected)
+	}
+	if len(res) != 1 {
+		t.Errorf("unexpected sinks: %v", res)
+	}
+}
+
+func TestGraphRoundRobin(t *testing.T) {
+	g := NewGraph()
+	AddNode(g, "src", mapStage(func(v int) int { return v }))
+	AddNode(g, "a", mapStage(func(v int) int { return v }))
+	AddNode(g, "b", mapStage(func(v int) int { return v }))
+	g.Connect("src", "a").Connect("src", "b").SetFanOut("src", RoundRobin)
+
+	res, err := g.Run(context.Background(), 0, 1, 2, 3, 4, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res["a"]) != 3 || len(res["b"]) != 3 {
+		t.Errorf("values are not spread evenly\nGot: a=%v b=%v", res["a"], res["b"])
+	}
+}
+
+func TestGraphRoute(t *testing.T) {
+	g := NewGraph()
+	AddNode(g, "src", mapStage(func(v int) int { return v }))
+	AddNode(g, "even", mapStage(func(v int) int { return v }))
+	AddNode(g, "odd", mapStage(func(v int) int { return v }))
+	g.Route("src", "even", func(val interface{}) bool { return val.(int)%2 == 0 }).
+		Route("src", "odd", func(val interface{}) bool { return val.(int)%2 == 1 })
+
+	res, err := g.Run(context.Background(), 1, 2, 3, 4, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := sortedInts(res["even"]); !equalInts(got, []int{2, 4}) {
+		t.Errorf("wrong even values\nGot: %v\nExpected: %v", got, []int{2, 4})
+	}
+	if got := sortedInts(res["odd"]); !equalInts(got, []int{1, 3, 5}) {
+		t.Errorf("wrong odd values\nGot: %v\nExpected: %v", got, []int{1, 3, 5})
+	}
+}
+
+func TestGraphValidate(t *testing.T) {
+	g := NewGraph()
+	AddNode(g, "a", mapStage(func(v int) int { return v }))
+	AddNode(g, "b", mapStage(func(v int) int { return v }))
+	AddNode(g, "c", mapStage(func(v int) int { return v }))
+	g.Connect("a", "b").Connect("b", "c").Connect("c", "b")
+
+	err := g.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cycle b -> c -> b") {
+		t.Errorf("cycle not detected\nGot: %v", err)
+	}
+	if _, err := g.Run(context.Background(), 1); err == nil {
+		t.Errorf("graph with cycle was run")
+	}
+
+	g = NewGraph()
+	AddNode(g, "a", mapStage(func(v int) int { return v }))
+	g.Connect("a", "missing")
+	if err := g.Validate(); err == nil || !strings.Contains(err.Error(), `unknown node "missing"`) {
+		t.Errorf("unknown node not detected\nGot: %v", err)
+	}
+}
+
+func TestGraphError(t *testing.T) {
+	errBad := errors.New("bad value")
+	g := NewGraph()
+	AddNode(g, "src", mapStage(func(v int) int { return v }))
+	AddNode(g, "fail", Stage[int, int](func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			if val == 2 {
+				return ItemError(val, errBad)
+			}
+		}
+		return nil
+	}))
+	AddNode(g, "ok", mapStage(func(v int) int { return v }))
+	g.Connect("src", "fail").Connect("src", "ok")
+
+	_, err := g.Run(context.Background(), 1, 2, 3)
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || !errors.Is(err, errBad) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stageErr.Stage != 1 || stageErr.Input != 2 {
+		t.Errorf("wrong stage error\nGot: stage %d, input %v\nExpected: stage 1, input 2", stageErr.Stage, stageErr.Input)
+	}
+}
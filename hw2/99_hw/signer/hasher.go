@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hasher считает подписи SingleHash/MultiHash/CombineResults на заданных Signer.
+// Разные Hasher не делят состояние, поэтому конвейеры с разными солями можно запускать одновременно.
+type Hasher struct {
+	checksum Signer // в схеме по умолчанию — crc32
+	digest   Signer // в схеме по умолчанию — md5
+
+	rounds     int
+	singleSep  string
+	combineSep string
+	sortOrder  SortOrder
+
+	checksumCache *Cache
+	digestCache   *Cache
+
+	logger    *slog.Logger
+	stageOpts []StageOption
+
+	fpMu sync.Mutex
+	fp   string
+}
+
+// HasherOption настраивает Hasher.
+type HasherOption func(*Hasher)
+
+// WithChecksum задаёт Signer, который используется вместо DataSignerCrc32.
+func WithChecksum(s Signer) HasherOption {
+	return func(h *Hasher) {
+		h.checksum = s
+	}
+}
+
+// WithDigest задаёт Signer, который используется вместо DataSignerMd5.
+func WithDigest(s Signer) HasherOption {
+	return func(h *Hasher) {
+		h.digest = s
+	}
+}
+
+// WithCache кеширует результаты checksum и digest: у каждого свой Cache на size записей.
+func WithCache(size int, ttl time.Duration) HasherOption {
+	return func(h *Hasher) {
+		h.checksumCache = NewCache(size, ttl)
+		h.digestCache = NewCache(size, ttl)
+	}
+}
+
+// WithStageOptions задаёт опции, с которыми создаются все стадии Hasher, в том числе
+// SingleHash и MultiHash старого образца. Например, WithStageOptions(WithCheckpoint(cp))
+// сохраняет результаты и для ExecutePipeline.
+func WithStageOptions(opts ...StageOption) HasherOption {
+	return func(h *Hasher) {
+		h.stageOpts = append(h.stageOpts, opts...)
+	}
+}
+
+// NewHasher создаёт Hasher. Без опций он работает через глобальные DataSignerCrc32 и DataSignerMd5,
+// причём вызовы DataSignerMd5 идут строго по одному.
+func NewHasher(opts ...HasherOption) *Hasher {
+	h := &Hasher{
+		checksum: globalCrc32,
+		digest:   LimitedSigner(globalMd5, NewLimiter(WithMaxInFlight(1))),
+
+		rounds:     6,
+		singleSep:  "~",
+		combineSep: "_",
+		sortOrder:  SortLexical,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.checksumCache != nil {
+		h.checksum = CachedSigner(h.checksum, h.checksumCache)
+		h.digest = CachedSigner(h.digest, h.digestCache)
+	}
+	return h
+}
+
+// CacheStats возвращает счётчики кешей checksum и digest, если включён WithCache.
+func (h *Hasher) CacheStats() (checksum, digest CacheStats) {
+	if h.checksumCache == nil {
+		return CacheStats{}, CacheStats{}
+	}
+	return h.checksumCache.Stats(), h.digestCache.Stats()
+}
+
+var defaultHasher = NewHasher()
+
+// stageConfig собирает настройки стадии name: сначала значения по умолчанию от Hasher, затем opts.
+func (h *Hasher) stageConfig(name string, opts []StageOption) stageConfig {
+	logger := h.logger
+	if logger == nil {
+		logger = currentLogger()
+	}
+	defaults := append([]StageOption{WithName(name), withLogger(logger), withFingerprint(h.fingerprint)}, h.stageOpts...)
+	return newStageConfig(append(defaults, opts...))
+}
+
+// fingerprintProbe подписывается checksum и digest, чтобы отличать схемы с разными солями и алгоритмами.
+const fingerprintProbe = "signer fingerprint probe"
+
+// fingerprint — отпечаток схемы подписи: ответы checksum и digest на пробную строку и параметры Hasher.
+// Считается при первом обращении и запоминается; для стандартных DataSigner* это занимает около секунды.
+func (h *Hasher) fingerprint(ctx context.Context) (string, error) {
+	h.fpMu.Lock()
+	defer h.fpMu.Unlock()
+	if h.fp != "" {
+		return h.fp, nil
+	}
+
+	checksum, err := h.checksum.Sign(ctx, fingerprintProbe)
+	if err != nil {
+		return "", err
+	}
+	digest, err := h.digest.Sign(ctx, fingerprintProbe)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{checksum, digest, strconv.Itoa(h.rounds), h.singleSep, h.combineSep, strconv.Itoa(int(h.sortOrder))}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	h.fp = hex.EncodeToString(sum[:8])
+	return h.fp, nil
+}
+
+func (h *Hasher) SingleHash(in, out chan interface{}) {
+	toJob(erase(h.SingleHashStage()))(in, out)
+}
+
+func (h *Hasher) MultiHash(in, out chan interface{}) {
+	toJob(erase(h.MultiHashStage()))(in, out)
+}
+
+func (h *Hasher) CombineResults(in, out chan interface{}) {
+	toJob(erase(h.CombineResultsStage()))(in, out)
+}
+
+// Pipeline собирает SingleHash -> MultiHash -> CombineResults в типизированный конвейер,
+// opts применяются ко всем трём стадиям.
+func (h *Hasher) Pipeline(opts ...StageOption) *Pipeline[int, string] {
+	return Then(Then(NewPipeline(h.SingleHashStage(opts...)), h.MultiHashStage(opts...)), h.CombineResultsStage(opts...))
+}
+
+// SingleHashStage считает checksum(data)+"~"+checksum(digest(data)), разделитель настраивается WithSingleSeparator.
+func (h *Hasher) SingleHashStage(opts ...StageOption) Stage[int, string] {
+	cfg := h.stageConfig("SingleHash", opts)
+	return func(ctx context.Context, in <-chan int, out chan<- string) error {
+		return processItems(ctx, in, out, cfg, func(ctx context.Context, num int) (string, error) {
+			var x1, x2 string
+			var err1, err2 error
+			wgIn := sync.WaitGroup{}
+			wgIn.Add(2)
+			str := strconv.Itoa(num)
+
+			go func() {
+				defer wgIn.Done()
+				x1, err1 = h.checksum.Sign(ctx, str)
+			}()
+			go func() {
+				defer wgIn.Done()
+
+				var digest string
+				digest, err2 = h.digest.Sign(ctx, str)
+				if err2 == nil {
+					x2, err2 = h.checksum.Sign(ctx, digest)
+				}
+			}()
+			wgIn.Wait()
+
+			if err := firstError(err1, err2); err != nil {
+				return "", ItemError(num, err)
+			}
+
+			return x1 + h.singleSep + x2, nil
+		})
+	}
+}
+
+// MultiHashStage считает конкатенацию checksum(th+data) для th=0..rounds-1 (по умолчанию 0..5), см. WithRounds.
+func (h *Hasher) MultiHashStage(opts ...StageOption) Stage[string, string] {
+	n := h.rounds
+	cfg := h.stageConfig("MultiHash", opts)
+	return func(ctx context.Context, in <-chan string, out chan<- string) error {
+		return processItems(ctx, in, out, cfg, func(ctx context.Context, val string) (string, error) {
+			result := make([]string, n)
+			errs := make([]error, n)
+			wgIn := sync.WaitGroup{}
+			for th := 0; th < n; th++ {
+				wgIn.Add(1)
+				go func(th int) {
+					defer wgIn.Done()
+					result[th], errs[th] = h.checksum.Sign(ctx, strconv.Itoa(th)+val)
+				}(th)
+			}
+			wgIn.Wait()
+
+			if err := firstError(errs...); err != nil {
+				return "", ItemError(val, err)
+			}
+
+			return strings.Join(result, ""), nil
+		})
+	}
+}
+
+// CombineResultsStage сортирует все результаты и склеивает их через "_", см. WithSortOrder и WithCombineSeparator.
+// С WithSpill большие объёмы сортируются через временные файлы, а с WithChunkSize склейка
+// отдаётся кусками по мере слияния и целиком в памяти не собирается.
+func (h *Hasher) CombineResultsStage(opts ...StageOption) Stage[string, string] {
+	cfg := h.stageConfig("CombineResults", opts)
+	return Observe(cfg.name, cfg.observer, func(ctx context.Context, in <-chan string, out chan<- string) error {
+		c := h.newCombiner(cfg)
+		defer c.close()
+		for {
+			val, err := recv(ctx, in)
+			if err == errClosed {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.add(val); err != nil {
+				return err
+			}
+		}
+		cfg.logger.Info("stage finished", "stage", cfg.name, "items", c.count)
+		if cfg.chunkSize > 0 {
+			cw := &chunkWriter{ctx: ctx, out: out, size: cfg.chunkSize}
+			if err := c.writeTo(cw, false); err != nil {
+				return err
+			}
+			return cw.flush()
+		}
+		res := strings.Builder{}
+		if err := c.writeTo(&res, false); err != nil {
+			return err
+		}
+		return send(ctx, out, res.String())
+	})
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
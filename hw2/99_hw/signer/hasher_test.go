@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestHasherFastSigners(t *testing.T) {
+	testExpected := "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"
+
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")))
+	results, err := h.Pipeline().Run(context.Background(), 0, 1, 1, 2, 3, 5, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0] != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, testExpected)
+	}
+}
+
+func TestHasherSaltsConcurrently(t *testing.T) {
+	signers := map[string]func(salt string) Signer{
+		"crc32":  NewCRC32Signer,
+		"md5":    NewMD5Signer,
+		"sha256": NewSHA256Signer,
+		"xxhash": NewXXHashSigner,
+		"hmac":   func(salt string) Signer { return NewHMACSigner([]byte(salt)) },
+	}
+
+	for name, newSigner := range signers {
+		var wg sync.WaitGroup
+		results := make([]string, 2)
+		for i, salt := range []string{"salt-a", "salt-b"} {
+			wg.Add(1)
+			go func(i int, salt string) {
+				defer wg.Done()
+				h := NewHasher(WithChecksum(newSigner(salt)), WithDigest(NewMD5Signer(salt)))
+				res, err := h.Pipeline().Run(context.Background(), 1, 2, 3)
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", name, err)
+					return
+				}
+				results[i] = res[0]
+			}(i, salt)
+		}
+		wg.Wait()
+
+		if results[0] == "" || results[0] == results[1] {
+			t.Errorf("%s: different salts gave the same result %q", name, results[0])
+		}
+	}
+}
+
+func TestHasherScheme(t *testing.T) {
+	h := NewHasher(
+		WithChecksum(NewCRC32Signer("")),
+		WithDigest(NewMD5Signer("")),
+		WithRounds(2),
+		WithSingleSeparator("-"),
+	)
+	single, err := NewPipeline(h.SingleHashStage()).Run(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if single[0] != "4108050209-502633748" {
+		t.Errorf("wrong SingleHash\nGot: %v\nExpected: %v", single[0], "4108050209-502633748")
+	}
+
+	multi, err := NewPipeline(h.MultiHashStage()).Run(context.Background(), "4108050209~502633748")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if multi[0] != "2956866606803518384" {
+		t.Errorf("wrong MultiHash\nGot: %v\nExpected: %v", multi[0], "2956866606803518384")
+	}
+
+	// раундов меньше одного не бывает
+	for _, n := range []int{0, -3} {
+		one := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")), WithRounds(n))
+		got, err := NewPipeline(one.MultiHashStage()).Run(context.Background(), "4108050209~502633748")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got[0] != "2956866606" {
+			t.Errorf("WithRounds(%d)\nGot: %v\nExpected: %v", n, got[0], "2956866606")
+		}
+	}
+
+	cases := []struct {
+		order    SortOrder
+		expected string
+	}{
+		{SortLexical, "10|100|9"},
+		{SortNumeric, "9|10|100"},
+	}
+	for _, item := range cases {
+		h := NewHasher(WithCombineSeparator("|"), WithSortOrder(item.order))
+		res, err := NewPipeline(h.CombineResultsStage()).Run(context.Background(), "10", "9", "100")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res[0] != item.expected {
+			t.Errorf("wrong CombineResults for order %d\nGot: %v\nExpected: %v", item.order, res[0], item.expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter ограничивает вызовы по скорости (token bucket) и по числу одновременных вызовов.
+// Ожидающие обслуживаются строго в порядке очереди, отмена ctx снимает вызывающего с очереди.
+type Limiter struct {
+	mu sync.Mutex
+
+	rate   float64 // токенов в секунду, 0 — скорость не ограничена
+	burst  int
+	tokens float64
+	last   time.Time
+	timer  *time.Timer
+
+	maxInFlight int // 0 — число одновременных вызовов не ограничено
+	inFlight    int
+
+	queue []*limiterWaiter
+	stats LimiterStats
+}
+
+// LimiterStats — накопленная статистика ожиданий в Limiter.
+type LimiterStats struct {
+	Acquired  int
+	Waited    int
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+type limiterWaiter struct {
+	ready    chan struct{}
+	admitted bool
+}
+
+// LimiterOption настраивает Limiter.
+type LimiterOption func(*Limiter)
+
+// WithRate разрешает perSecond вызовов в секунду с запасом burst токенов.
+func WithRate(perSecond float64, burst int) LimiterOption {
+	return func(l *Limiter) {
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = perSecond
+		l.burst = burst
+		l.tokens = float64(burst)
+	}
+}
+
+// WithMaxInFlight разрешает не больше n одновременных вызовов.
+func WithMaxInFlight(n int) LimiterOption {
+	return func(l *Limiter) {
+		l.maxInFlight = n
+	}
+}
+
+func NewLimiter(opts ...LimiterOption) *Limiter {
+	l := &Limiter{last: time.Now()}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Acquire ждёт своей очереди и возвращает время ожидания. После работы нужно вызвать Release.
+// Если ctx отменён раньше, возвращается его ошибка, а место в очереди освобождается.
+func (l *Limiter) Acquire(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+
+	l.mu.Lock()
+	l.refillLocked(start)
+	if len(l.queue) == 0 && l.canAdmitLocked() {
+		l.admitLocked()
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	w := &limiterWaiter{ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.dispatchLocked()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		waited := time.Since(start)
+		l.mu.Lock()
+		l.recordWaitLocked(waited)
+		l.mu.Unlock()
+		return waited, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.admitted {
+		// место выдали одновременно с отменой — возвращаем его следующему
+		l.releaseLocked()
+	} else {
+		l.removeLocked(w)
+		l.dispatchLocked()
+	}
+	return time.Since(start), ctx.Err()
+}
+
+// Release освобождает место, полученное через Acquire.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked()
+}
+
+// Stats возвращает статистику ожиданий.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+func (l *Limiter) canAdmitLocked() bool {
+	if l.maxInFlight > 0 && l.inFlight >= l.maxInFlight {
+		return false
+	}
+	return l.rate <= 0 || l.tokens >= 1
+}
+
+func (l *Limiter) admitLocked() {
+	l.inFlight++
+	if l.rate > 0 {
+		l.tokens--
+	}
+	l.stats.Acquired++
+}
+
+func (l *Limiter) recordWaitLocked(waited time.Duration) {
+	l.stats.Waited++
+	l.stats.TotalWait += waited
+	if waited > l.stats.MaxWait {
+		l.stats.MaxWait = waited
+	}
+}
+
+func (l *Limiter) releaseLocked() {
+	l.inFlight--
+	l.dispatchLocked()
+}
+
+func (l *Limiter) refillLocked(now time.Time) {
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// dispatchLocked пропускает ожидающих с головы очереди, пока хватает мест и токенов.
+// Если голове не хватает только токена, заводит таймер до его появления.
+func (l *Limiter) dispatchLocked() {
+	l.refillLocked(time.Now())
+	for len(l.queue) > 0 {
+		if !l.canAdmitLocked() {
+			if l.rate > 0 && l.tokens < 1 && l.timer == nil {
+				wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+				l.timer = time.AfterFunc(wait, l.onTimer)
+			}
+			return
+		}
+
+		w := l.queue[0]
+		l.queue[0] = nil
+		l.queue = l.queue[1:]
+		l.admitLocked()
+		w.admitted = true
+		close(w.ready)
+	}
+}
+
+func (l *Limiter) onTimer() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timer = nil
+	l.dispatchLocked()
+}
+
+func (l *Limiter) removeLocked(w *limiterWaiter) {
+	for i, queued := range l.queue {
+		if queued == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// LimitedSigner пропускает вызовы s через l.
+func LimitedSigner(s Signer, l *Limiter) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		if _, err := l.Acquire(ctx); err != nil {
+			return "", err
+		}
+		defer l.Release()
+		return s.Sign(ctx, data)
+	})
+}
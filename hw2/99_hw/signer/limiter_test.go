@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterFIFO(t *testing.T) {
+	l := NewLimiter(WithMaxInFlight(1))
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			l.Release()
+		}(i)
+		time.Sleep(5 * time.Millisecond) // гарантируем порядок постановки в очередь
+	}
+
+	l.Release()
+	wg.Wait()
+
+	for i, val := range order {
+		if val != i {
+			t.Fatalf("not FIFO\nGot: %v", order)
+		}
+	}
+	if stats := l.Stats(); stats.Waited != 5 || stats.MaxWait <= 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter(WithRate(100, 1))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		l.Release()
+	}
+	end := time.Since(start)
+
+	if end < 35*time.Millisecond {
+		t.Errorf("rate is not limited\nGot: %s\nExpected: >=%s", end, 40*time.Millisecond)
+	}
+}
+
+func TestLimiterDeadline(t *testing.T) {
+	l := NewLimiter(WithMaxInFlight(1))
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error\nGot: %v\nExpected: %v", err, context.DeadlineExceeded)
+	}
+
+	// отменённый вызов не должен занимать очередь
+	l.Release()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
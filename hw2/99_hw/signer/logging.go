@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+var packageLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	packageLogger.Store(slog.New(discardHandler{}))
+}
+
+// SetLogger задаёт логгер пакета. Его используют OverheatLock и Hasher без WithLogger.
+// По умолчанию пакет ничего не пишет, nil возвращает это поведение.
+// Значения по стадиям пишутся на уровне Debug, итоги стадий — Info, ошибки — Error,
+// так что подробность настраивается уровнем обработчика.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(discardHandler{})
+	}
+	packageLogger.Store(l)
+}
+
+func currentLogger() *slog.Logger {
+	return packageLogger.Load()
+}
+
+// WithLogger задаёт логгер для стадий Hasher вместо логгера пакета.
+func WithLogger(l *slog.Logger) HasherOption {
+	return func(h *Hasher) {
+		h.logger = l
+	}
+}
+
+// discardHandler молча отбрасывает записи, не форматируя их.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestHasherLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")), WithLogger(logger))
+
+	if _, err := NewPipeline(h.SingleHashStage()).Run(context.Background(), 42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var found bool
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		record := map[string]interface{}{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("cant decode log record: %v", err)
+		}
+		if record["msg"] == "item processed" && record["stage"] == "SingleHash" && record["input"] == float64(42) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("item record not found in log:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer получает события от стадий конвейера. Методы вызываются конкурентно.
+type Observer interface {
+	// ItemIn — стадия взяла значение со входа.
+	ItemIn(stage string)
+	// ItemOut — стадия отдала результат следующей, latency считается от взятия значения.
+	ItemOut(stage string, latency time.Duration)
+	// QueueDepth — сколько готовых результатов ждут, пока их заберёт следующая стадия.
+	QueueDepth(stage string, depth int)
+}
+
+// stageTracker сообщает Observer о прохождении значений через стадию, без Observer ничего не делает.
+type stageTracker struct {
+	name   string
+	obs    Observer
+	queued int64
+}
+
+func (t *stageTracker) in() time.Time {
+	if t.obs == nil {
+		return time.Time{}
+	}
+	t.obs.ItemIn(t.name)
+	return time.Now()
+}
+
+func (t *stageTracker) ready() {
+	if t.obs == nil {
+		return
+	}
+	t.obs.QueueDepth(t.name, int(atomic.AddInt64(&t.queued, 1)))
+}
+
+func (t *stageTracker) out(start time.Time) {
+	if t.obs == nil {
+		return
+	}
+	t.obs.QueueDepth(t.name, int(atomic.AddInt64(&t.queued, -1)))
+	t.obs.ItemOut(t.name, time.Since(start))
+}
+
+// Observe оборачивает произвольную стадию и сообщает obs о её входах и выходах.
+// Задержка выхода считается от самого раннего входа, для которого ещё не было выхода,
+// поэтому точна для стадий «одно значение на входе — одно на выходе».
+func Observe[In, Out any](name string, obs Observer, s Stage[In, Out]) Stage[In, Out] {
+	if obs == nil {
+		return s
+	}
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		tracker := &stageTracker{name: name, obs: obs}
+		var (
+			mu     sync.Mutex
+			starts []time.Time
+			last   = time.Now()
+		)
+
+		return bridge(ctx, in, out,
+			func(val In) (In, error) {
+				start := tracker.in()
+				mu.Lock()
+				starts = append(starts, start)
+				last = start
+				mu.Unlock()
+				return val, nil
+			},
+			func(val Out) Out {
+				mu.Lock()
+				start := last
+				if len(starts) > 0 {
+					start = starts[0]
+					starts = starts[1:]
+				}
+				mu.Unlock()
+				tracker.ready()
+				tracker.out(start)
+				return val
+			},
+			func(in chan In, out chan Out) error {
+				return s(ctx, in, out)
+			})
+	}
+}
+
+// ObserveJob — то же, что Observe, для стадий старого образца.
+func ObserveJob(name string, obs Observer, fn job) job {
+	return toJob(Observe(name, obs, fromJob(fn)))
+}
+
+// DefaultLatencyBuckets — границы гистограммы задержек по умолчанию.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// Metrics — Observer, который копит счётчики по стадиям и отдаёт их в текстовом формате Prometheus.
+type Metrics struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	stages  map[string]*stageMetrics
+}
+
+type stageMetrics struct {
+	in, out    uint64
+	queueDepth int
+	counts     []uint64 // по одному на границу buckets, не накопительно
+	sum        time.Duration
+}
+
+// NewMetrics создаёт Metrics с заданными границами гистограммы (по умолчанию DefaultLatencyBuckets).
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return &Metrics{buckets: buckets, stages: make(map[string]*stageMetrics)}
+}
+
+func (m *Metrics) ItemIn(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stageLocked(stage).in++
+}
+
+func (m *Metrics) ItemOut(stage string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stageLocked(stage)
+	s.out++
+	s.sum += latency
+	for i, bound := range m.buckets {
+		if latency <= bound {
+			s.counts[i]++
+			return
+		}
+	}
+}
+
+func (m *Metrics) QueueDepth(stage string, depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stageLocked(stage).queueDepth = depth
+}
+
+func (m *Metrics) stageLocked(stage string) *stageMetrics {
+	s, ok := m.stages[stage]
+	if !ok {
+		s = &stageMetrics{counts: make([]uint64, len(m.buckets))}
+		m.stages[stage] = s
+	}
+	return s
+}
+
+// WritePrometheus пишет снимок метрик в текстовом формате Prometheus.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.stages))
+	for name := range m.stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ew := &errWriter{w: w}
+	gauge := func(metric, help, typ string, value func(s *stageMetrics) int64) {
+		ew.printf("# HELP %s %s\n# TYPE %s %s\n", metric, help, metric, typ)
+		for _, name := range names {
+			ew.printf("%s{stage=%q} %d\n", metric, name, value(m.stages[name]))
+		}
+	}
+
+	gauge("signer_stage_items_in_total", "Items taken by the stage.", "counter",
+		func(s *stageMetrics) int64 { return int64(s.in) })
+	gauge("signer_stage_items_out_total", "Items emitted by the stage.", "counter",
+		func(s *stageMetrics) int64 { return int64(s.out) })
+	gauge("signer_stage_in_flight", "Items taken but not yet emitted.", "gauge",
+		func(s *stageMetrics) int64 { return int64(s.in) - int64(s.out) })
+	gauge("signer_stage_queue_depth", "Results waiting for the next stage.", "gauge",
+		func(s *stageMetrics) int64 { return int64(s.queueDepth) })
+
+	const latency = "signer_stage_latency_seconds"
+	ew.printf("# HELP %s Time from taking an item to emitting its result.\n# TYPE %s histogram\n", latency, latency)
+	for _, name := range names {
+		s := m.stages[name]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += s.counts[i]
+			ew.printf("%s_bucket{stage=%q,le=%q} %d\n", latency, name, fmt.Sprint(bound.Seconds()), cumulative)
+		}
+		ew.printf("%s_bucket{stage=%q,le=\"+Inf\"} %d\n", latency, name, s.out)
+		ew.printf("%s_sum{stage=%q} %g\n", latency, name, s.sum.Seconds())
+		ew.printf("%s_count{stage=%q} %d\n", latency, name, s.out)
+	}
+
+	return ew.err
+}
+
+// errWriter запоминает первую ошибку записи, чтобы не проверять каждый Fprintf.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMetricsPrometheus(t *testing.T) {
+	m := NewMetrics()
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")))
+
+	if _, err := h.Pipeline(WithObserver(m)).Run(context.Background(), 0, 1, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := m.WritePrometheus(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := buf.String()
+
+	expectedLines := []string{
+		`signer_stage_items_in_total{stage="SingleHash"} 3`,
+		`signer_stage_items_out_total{stage="MultiHash"} 3`,
+		`signer_stage_items_in_total{stage="CombineResults"} 3`,
+		`signer_stage_items_out_total{stage="CombineResults"} 1`,
+		`signer_stage_in_flight{stage="SingleHash"} 0`,
+		`signer_stage_queue_depth{stage="MultiHash"} 0`,
+		`signer_stage_latency_seconds_count{stage="SingleHash"} 3`,
+		`signer_stage_latency_seconds_bucket{stage="SingleHash",le="+Inf"} 3`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(report, line+"\n") {
+			t.Errorf("line %q not found in report:\n%s", line, report)
+		}
+	}
+}
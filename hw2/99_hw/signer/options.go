@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StageOption настраивает стадию SingleHash, MultiHash или CombineResults.
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	name       string
+	workers    int
+	ordered    bool
+	observer   Observer
+	logger     *slog.Logger
+	checkpoint *Checkpoint
+	// fingerprint описывает схему, по которой стадия считает результаты, см. Hasher.fingerprint
+	fingerprint func(ctx context.Context) (string, error)
+	retry       *RetryPolicy
+
+	snapshotEvery    int
+	snapshotInterval time.Duration
+	spillAt          int
+	spillDir         string
+	chunkSize        int
+}
+
+func newStageConfig(opts []StageOption) stageConfig {
+	cfg := stageConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.logger == nil {
+		cfg.logger = currentLogger()
+	}
+	return cfg
+}
+
+// WithWorkers ограничивает число значений, которые стадия обрабатывает одновременно.
+// Пока все слоты заняты, стадия не читает вход, и давление передаётся предыдущим стадиям.
+// При n <= 0 ограничения нет: на каждое значение запускается своя горутина.
+func WithWorkers(n int) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.workers = n
+	}
+}
+
+// WithOrdered включает выдачу результатов в порядке входных значений.
+// Результаты, готовые раньше предыдущих, ждут в буфере, остальные уходят дальше сразу.
+func WithOrdered() StageOption {
+	return func(cfg *stageConfig) {
+		cfg.ordered = true
+	}
+}
+
+// WithName задаёт имя стадии для Observer, по умолчанию это имя функции стадии.
+func WithName(name string) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.name = name
+	}
+}
+
+// WithObserver сообщает obs о каждом значении, прошедшем через стадию, см. Observer.
+func WithObserver(obs Observer) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.observer = obs
+	}
+}
+
+func withLogger(l *slog.Logger) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.logger = l
+	}
+}
+
+func withFingerprint(fn func(ctx context.Context) (string, error)) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.fingerprint = fn
+	}
+}
+
+// WithCheckpoint сохраняет результаты стадии в cp и при повторном запуске берёт готовые оттуда.
+// Записи различаются по имени стадии, поэтому одинаковые стадии должны называться по-разному (WithName).
+// Журнал, записанный стадией Hasher с другими подписчиками, солями или параметрами, не принимается.
+func WithCheckpoint(cp *Checkpoint) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.checkpoint = cp
+	}
+}
+
+// WithRetry повторяет обработку значения по правилам policy, см. RetryPolicy.
+func WithRetry(policy RetryPolicy) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.retry = &policy
+	}
+}
+
+// WithSnapshotEvery заставляет StreamCombineResultsStage выдавать промежуточный результат
+// после каждых n значений.
+func WithSnapshotEvery(n int) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.snapshotEvery = n
+	}
+}
+
+// WithSnapshotInterval заставляет StreamCombineResultsStage выдавать промежуточный результат
+// раз в d, если с прошлого раза пришли новые значения.
+func WithSnapshotInterval(d time.Duration) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.snapshotInterval = d
+	}
+}
+
+// WithSpill ограничивает число результатов, которые CombineResults держит в памяти: как только их
+// набирается n, они сортируются и сбрасываются во временный файл в dir ("" — os.TempDir),
+// а в конце файлы сливаются внешней сортировкой.
+func WithSpill(n int, dir string) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.spillAt = n
+		cfg.spillDir = dir
+	}
+}
+
+// WithChunkSize заставляет CombineResultsStage отдавать склейку кусками не больше n байт:
+// результат — их конкатенация. Вместе с WithSpill память не зависит от объёма результатов.
+func WithChunkSize(n int) StageOption {
+	return func(cfg *stageConfig) {
+		cfg.chunkSize = n
+	}
+}
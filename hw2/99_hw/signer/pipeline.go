@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Stage — типизированная стадия конвейера: читает In, пишет Out, видит ctx и может вернуть ошибку.
+// Закрывать out не нужно, это делает конвейер после возврата из стадии.
+type Stage[In, Out any] func(ctx context.Context, in <-chan In, out chan<- Out) error
+
+// stage — нетипизированная стадия, в таком виде стадии хранятся и запускаются конвейером.
+type stage = Stage[interface{}, interface{}]
+
+// StageError описывает ошибку стадии: её номер в конвейере и значение, на котором она упала.
+type StageError struct {
+	Stage int
+	Input interface{}
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	if e.Input == nil {
+		return fmt.Sprintf("stage %d: %s", e.Stage, e.Err)
+	}
+	return fmt.Sprintf("stage %d: input %v: %s", e.Stage, e.Input, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// ItemError привязывает ошибку к входному значению, номер стадии проставит конвейер.
+func ItemError(input interface{}, err error) error {
+	return &StageError{Stage: -1, Input: input, Err: err}
+}
+
+// ExecuteStages запускает стадии конвейером. Первая ошибка отменяет ctx у всех стадий,
+// поэтому источники данных перестают производить значения, и возвращается как *StageError.
+func ExecuteStages(ctx context.Context, stages ...stage) error {
+	in := make(chan interface{})
+	close(in)
+	return runStages(ctx, in, nil, stages)
+}
+
+// runStages соединяет стадии каналами: первая читает из in, выход последней уходит в out
+// (если out == nil — вычитывается и выбрасывается). Сам out не закрывается, но после возврата
+// в него ничего не пишется, так что вызывающий может его закрыть.
+func runStages(ctx context.Context, in <-chan interface{}, out chan<- interface{}, stages []stage) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	setErr := func(i int, err error) {
+		mu.Lock()
+		if firstErr == nil && parent.Err() == nil {
+			firstErr = withStage(i, err)
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	wg := sync.WaitGroup{}
+	for i, stageFunc := range stages {
+		stageOut := make(chan interface{})
+		wg.Add(1)
+
+		go func(i int, fn stage, in <-chan interface{}, out chan interface{}) {
+			defer wg.Done()
+			err := fn(ctx, in, out)
+			close(out)
+			if err != nil {
+				setErr(i, err)
+			}
+		}(i, stageFunc, in, stageOut)
+
+		in = stageOut
+	}
+
+	// forwarded ждём всегда: после возврата вызывающий закрывает out, и запоздавшая запись в него упадёт
+	forwarded := sync.WaitGroup{}
+	if out == nil {
+		go drain(in)
+	} else {
+		wg.Add(1)
+		forwarded.Add(1)
+		go func(in <-chan interface{}) {
+			defer wg.Done()
+			defer forwarded.Done()
+			for {
+				val, err := recv(ctx, in)
+				if err == errClosed {
+					return
+				}
+				if err == nil {
+					err = send(ctx, out, val)
+				}
+				if err != nil {
+					go drain(in)
+					return
+				}
+			}
+		}(in)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	forwarded.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return fmt.Errorf("execute pipeline: %w", err)
+	}
+	return nil
+}
+
+// ExecutePipelineContext работает как ExecutePipeline, но умеет останавливаться по ctx.
+// При отмене вход каждой стадии закрывается, а её выход вычитывается в фоне,
+// поэтому даже зависшая стадия не держит вызывающего: функция сразу возвращает ошибку.
+func ExecutePipelineContext(ctx context.Context, jobs ...job) error {
+	stages := make([]stage, 0, len(jobs))
+	for _, jobFunc := range jobs {
+		stages = append(stages, fromJob(jobFunc))
+	}
+	return ExecuteStages(ctx, stages...)
+}
+
+// fromJob превращает job в stage: значения ходят через промежуточные каналы,
+// которые перестают работать при отмене ctx. jobFailure из выхода job становится ошибкой стадии.
+func fromJob(fn job) stage {
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		return bridge(ctx, in, out, same[interface{}], identity,
+			func(in, out chan interface{}) error {
+				return runJob(fn, in, out)
+			})
+	}
+}
+
+// runJob запускает fn и пересылает его выход в out, перехватывая jobFailure.
+// Возвращает первую из перехваченных ошибок после того, как fn завершится.
+func runJob(fn job, in, out chan interface{}) error {
+	jobOut := make(chan interface{})
+	go func() {
+		fn(in, jobOut)
+		close(jobOut)
+	}()
+
+	var err error
+	for val := range jobOut {
+		if failure, ok := val.(jobFailure); ok {
+			if err == nil {
+				err = failure.err
+			}
+			continue
+		}
+		out <- val
+	}
+	return err
+}
+
+// jobFailure передаёт ошибку стадии из job, который не может её вернуть.
+// Такие значения перехватывает ExecutePipeline, до следующего job они не доходят.
+type jobFailure struct {
+	err error
+}
+
+// toJob превращает stage обратно в job для старого кода. Ошибка стадии уходит в out
+// значением jobFailure, остаток входа вычитывается.
+func toJob(fn stage) job {
+	return func(in, out chan interface{}) {
+		if err := fn(context.Background(), in, out); err != nil {
+			out <- jobFailure{err: err}
+		}
+		drain(in)
+	}
+}
+
+// erase стирает типы стадии, чтобы её можно было запустить в общем конвейере.
+// Значение не того типа на входе останавливает стадию с ошибкой.
+func erase[In, Out any](fn Stage[In, Out]) stage {
+	return func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		return bridge(ctx, in, out, assertTo[In], func(val Out) interface{} { return val },
+			func(in chan In, out chan Out) error {
+				return fn(ctx, in, out)
+			})
+	}
+}
+
+// bridge запускает run на собственных каналах и перекладывает в них значения из in и обратно в out,
+// по пути преобразуя типы. При отмене ctx возвращается сразу, не дожидаясь run.
+func bridge[A, In, Out, B any](ctx context.Context, in <-chan A, out chan<- B,
+	toIn func(A) (In, error), toOut func(Out) B, run func(in chan In, out chan Out) error) error {
+	runIn := make(chan In)
+	runOut := make(chan Out)
+	finished := make(chan struct{})
+
+	var runErr error
+	go func() {
+		defer close(finished)
+		defer close(runOut)
+		runErr = run(runIn, runOut)
+	}()
+
+	feedErr := make(chan error, 1)
+	go func() {
+		// ошибка кладётся до закрытия runIn: иначе run успеет завершиться и bridge её не увидит
+		if err := feed(ctx, in, runIn, finished, toIn); err != nil {
+			feedErr <- err
+		}
+		close(runIn)
+	}()
+
+	for {
+		select {
+		case val, ok := <-runOut:
+			if !ok {
+				if runErr != nil {
+					return runErr
+				}
+				select {
+				case err := <-feedErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			if err := send(ctx, out, toOut(val)); err != nil {
+				go drain(runOut)
+				return err
+			}
+		case <-ctx.Done():
+			go drain(runOut)
+			return ctx.Err()
+		}
+	}
+}
+
+var errClosed = errors.New("channel closed")
+
+// recv читает значение из in с учётом ctx, закрытый канал даёт errClosed.
+func recv[T any](ctx context.Context, in <-chan T) (T, error) {
+	select {
+	case val, ok := <-in:
+		if !ok {
+			return val, errClosed
+		}
+		return val, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// send пишет значение в out с учётом ctx.
+func send[T any](ctx context.Context, out chan<- T, val T) error {
+	select {
+	case out <- val:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// feed перекладывает значения из src во вход стадии dst, пока не отменён ctx
+// и пока стадия не завершилась. В обоих случаях src дочитывается в фоне,
+// чтобы предыдущая стадия не заблокировалась на записи. dst закрывает вызывающий.
+func feed[From, To any](ctx context.Context, src <-chan From, dst chan<- To, finished <-chan struct{},
+	conv func(From) (To, error)) error {
+	for {
+		select {
+		case val, ok := <-src:
+			if !ok {
+				return nil
+			}
+			converted, err := conv(val)
+			if err != nil {
+				go drain(src)
+				return err
+			}
+			select {
+			case dst <- converted:
+				continue
+			case <-ctx.Done():
+			case <-finished:
+			}
+		case <-ctx.Done():
+		case <-finished:
+		}
+		go drain(src)
+		return nil
+	}
+}
+
+func drain[T any](ch <-chan T) {
+	for range ch {
+	}
+}
+
+func same[T any](val T) (T, error) {
+	return val, nil
+}
+
+func identity(val interface{}) interface{} {
+	return val
+}
+
+func assertTo[T any](val interface{}) (T, error) {
+	typed, ok := val.(T)
+	if !ok {
+		return typed, ItemError(val, fmt.Errorf("expected %T, got %T", typed, val))
+	}
+	return typed, nil
+}
+
+func withStage(i int, err error) error {
+	var stageErr *StageError
+	if errors.As(err, &stageErr) && stageErr.Stage < 0 {
+		stageErr.Stage = i
+		return err
+	}
+	return &StageError{Stage: i, Err: err}
+}
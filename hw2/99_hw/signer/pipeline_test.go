@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hw/pipetest"
+)
+
+func TestExecutePipelineContextTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	stuckJobs := []job{
+		job(func(in, out chan interface{}) {
+			for i := 0; ; i++ {
+				out <- i
+			}
+		}),
+		job(func(in, out chan interface{}) {
+			for range in {
+				<-release // имитируем зависший DataSignerCrc32
+			}
+		}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := ExecutePipelineContext(ctx, stuckJobs...)
+	end := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error\nGot: %v\nExpected: %v", err, context.DeadlineExceeded)
+	}
+	if end > 500*time.Millisecond {
+		t.Errorf("pipeline was not stopped\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+}
+
+func TestExecutePipelineContextDone(t *testing.T) {
+	var recieved []interface{}
+	jobs := []job{
+		job(func(in, out chan interface{}) {
+			out <- 1
+			out <- 2
+		}),
+		job(func(in, out chan interface{}) {
+			for val := range in {
+				recieved = append(recieved, val)
+			}
+		}),
+	}
+
+	if err := ExecutePipelineContext(context.Background(), jobs...); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(recieved) != 2 {
+		t.Errorf("not all values recieved: %v", recieved)
+	}
+}
+
+func TestExecutePipelineJobError(t *testing.T) {
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")))
+	var (
+		mu       sync.Mutex
+		received []interface{}
+	)
+	jobs := []job{
+		job(func(in, out chan interface{}) {
+			out <- 1
+			out <- "oops"
+			out <- 2
+		}),
+		job(h.SingleHash),
+		job(func(in, out chan interface{}) {
+			for val := range in {
+				mu.Lock()
+				received = append(received, val)
+				mu.Unlock()
+			}
+		}),
+	}
+
+	err := ExecutePipeline(jobs...)
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != 1 || stageErr.Input != "oops" {
+		t.Errorf("unexpected error\nGot: %v\nExpected: stage 1 input oops", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, val := range received {
+		if _, ok := val.(string); !ok {
+			t.Errorf("job failure leaked to the next job: %#v", val)
+		}
+	}
+}
+
+func TestExecuteStagesError(t *testing.T) {
+	var produced uint32
+	stages := []stage{
+		func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+			for i := 0; ; i++ {
+				if err := send[interface{}](ctx, out, i); err != nil {
+					return err
+				}
+				atomic.AddUint32(&produced, 1)
+			}
+		},
+		func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+			for val := range in {
+				if val.(int) == 3 {
+					return ItemError(val, errors.New("bad value"))
+				}
+			}
+			return nil
+		},
+	}
+
+	err := ExecuteStages(context.Background(), stages...)
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("expected *StageError, got %v", err)
+	}
+	if stageErr.Stage != 1 || stageErr.Input != 3 {
+		t.Errorf("wrong error details\nGot: stage %d input %v\nExpected: stage 1 input 3", stageErr.Stage, stageErr.Input)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadUint32(&produced); n > 5 {
+		t.Errorf("producer was not stopped, produced %d values", n)
+	}
+}
+
+func TestPipelineRunStageError(t *testing.T) {
+	forward := func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			if err := send(ctx, out, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	failing := func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			if val == 5 {
+				return errors.New("bad value")
+			}
+			if err := send(ctx, out, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	p := Then(Then(NewPipeline(forward), failing), forward)
+	nested := NewPipeline(p.Stage())
+
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	// раньше последняя стадия могла писать в уже закрытый выход: panic: send on closed channel
+	for i := 0; i < 500; i++ {
+		if _, err := p.Run(context.Background(), inputs...); err == nil {
+			t.Fatalf("run %d: expected error", i)
+		}
+		if _, err := nested.Run(context.Background(), inputs...); err == nil {
+			t.Fatalf("run %d: expected error from nested pipeline", i)
+		}
+	}
+}
+
+func TestSingleHashStageBadInput(t *testing.T) {
+	stages := []stage{
+		func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+			return send[interface{}](ctx, out, "not a number")
+		},
+		erase(SingleHashStage()),
+	}
+
+	err := ExecuteStages(context.Background(), stages...)
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != 1 || stageErr.Input != "not a number" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEraseBadInputAlwaysReported(t *testing.T) {
+	source := func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		return send[interface{}](ctx, out, "x")
+	}
+	forward := erase(func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			if err := send(ctx, out, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for i := 0; i < 2000; i++ {
+		if err := ExecuteStages(context.Background(), source, forward); err == nil {
+			t.Fatalf("run %d: type error was lost", i)
+		}
+	}
+}
+
+func TestHashPipeline(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+
+	results, err := HashPipeline().Run(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0] != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, testExpected)
+	}
+}
+
+func TestProcessItemsWorkers(t *testing.T) {
+	const workers = 3
+	var inFlight, maxInFlight int32
+
+	in := make(chan int)
+	go func() {
+		for i := 0; i < 20; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	out := make(chan int, 20)
+	cfg := newStageConfig([]StageOption{WithWorkers(workers)})
+	err := processItems(context.Background(), in, out, cfg, func(ctx context.Context, val int) (int, error) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		for {
+			old := atomic.LoadInt32(&maxInFlight)
+			if cur <= old || atomic.CompareAndSwapInt32(&maxInFlight, old, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return val, nil
+	})
+	close(out)
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(out) != 20 {
+		t.Errorf("not all values processed: %d", len(out))
+	}
+	if maxInFlight > workers {
+		t.Errorf("too many workers\nGot: %d\nExpected: <=%d", maxInFlight, workers)
+	}
+}
+
+func TestProcessItemsOrdered(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	out := make(chan int, 10)
+	cfg := newStageConfig([]StageOption{WithWorkers(4), WithOrdered()})
+	err := processItems(context.Background(), in, out, cfg, func(ctx context.Context, val int) (int, error) {
+		time.Sleep(time.Duration(10-val) * time.Millisecond)
+		return val, nil
+	})
+	close(out)
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := 0
+	for val := range out {
+		if val != expected {
+			t.Errorf("wrong order\nGot: %d\nExpected: %d", val, expected)
+		}
+		expected++
+	}
+	if expected != 10 {
+		t.Errorf("not all values processed: %d", expected)
+	}
+}
+
+func TestExecutePipelineHygiene(t *testing.T) {
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")))
+	jobs := []job{
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 5; i++ {
+				out <- i
+			}
+		}),
+		job(h.SingleHash),
+		job(h.MultiHash),
+		job(h.CombineResults),
+		job(func(in, out chan interface{}) {
+			for range in {
+			}
+		}),
+	}
+	pipetest.Check(t, ExecutePipeline, jobs)
+}
@@ -0,0 +1,244 @@
+// Package pipetest проверяет конвейеры из job: что каждый вход закрыт, стадии дочитывают вход,
+// не закрывают выход сами, не пишут в него после возврата и не оставляют горутин.
+//
+// Проверка считает горутины всего процесса, поэтому тесты с ней нельзя запускать через t.Parallel.
+package pipetest
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TB — часть testing.TB, нужная Check.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Job — стадия конвейера старого образца, например job из signer.
+type Job interface {
+	~func(in, out chan interface{})
+}
+
+// Option настраивает Check.
+type Option func(*config)
+
+type config struct {
+	leakTimeout time.Duration
+}
+
+// WithLeakTimeout задаёт, сколько ждать завершения горутин после возврата конвейера (по умолчанию 1s).
+func WithLeakTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.leakTimeout = d
+	}
+}
+
+// stageState — что стадия сделала со своими каналами.
+type stageState struct {
+	mu        sync.Mutex
+	returned  bool
+	inClosed  bool
+	unread    int
+	late      int
+	stuck     bool
+	closedOut bool
+	panicVal  interface{}
+}
+
+// Check запускает jobs через execute (обычно ExecutePipeline) и сообщает в t обо всех нарушениях,
+// в том числе об ошибке, которую вернул execute.
+// Каждая стадия получает свои каналы, поэтому закрытие выхода самой стадией или запись в него
+// после возврата не роняют тест, а попадают в отчёт.
+func Check[J Job](t TB, execute func(jobs ...J) error, jobs []J, opts ...Option) {
+	t.Helper()
+	cfg := config{leakTimeout: time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	before := runtime.NumGoroutine()
+	stop := make(chan struct{})
+	proxies := &proxyGroup{}
+	states := make([]*stageState, len(jobs))
+	wrapped := make([]J, len(jobs))
+	for i, fn := range jobs {
+		states[i] = &stageState{}
+		wrapped[i] = J(wrap(fn, states[i], stop, proxies))
+	}
+
+	if err := execute(wrapped...); err != nil {
+		t.Errorf("pipeline returned error: %v", err)
+	}
+
+	// на стадию остаётся одна горутина, принимающая поздние записи
+	after, leaked := waitGoroutines(before+len(jobs), cfg.leakTimeout)
+	var stacks string
+	if leaked {
+		stacks = goroutineStacks()
+	}
+	close(stop)
+	proxies.wait()
+
+	for i, st := range states {
+		st.mu.Lock()
+		if st.panicVal != nil {
+			t.Errorf("stage %d panicked: %v", i, st.panicVal)
+		}
+		if !st.returned {
+			t.Errorf("stage %d did not return", i)
+		}
+		if !st.inClosed {
+			t.Errorf("stage %d: input channel was never closed", i)
+		}
+		if st.unread > 0 {
+			t.Errorf("stage %d returned with %d unread input values", i, st.unread)
+		}
+		if st.closedOut {
+			t.Errorf("stage %d closed its out channel: the pipeline closes it, a second close panics", i)
+		}
+		if st.stuck {
+			t.Errorf("stage %d: its output was never read, the next stage stopped reading", i)
+		}
+		if st.late > 0 {
+			t.Errorf("stage %d sent %d values after returning: out may be closed by then", i, st.late)
+		}
+		st.mu.Unlock()
+	}
+	if leaked {
+		t.Errorf("goroutines leaked after pipeline returned\nGot: %d\nExpected: <=%d\n%s",
+			after-len(jobs), before, stacks)
+	}
+}
+
+// wrap подменяет каналы стадии своими: in перекладывается во вход стадии, пока она работает,
+// выход стадии перекладывается в out до её возврата, а дальше только считается.
+func wrap(fn func(in, out chan interface{}), st *stageState, stop <-chan struct{},
+	proxies *proxyGroup) func(in, out chan interface{}) {
+	return func(in, out chan interface{}) {
+		jobIn := make(chan interface{})
+		jobOut := make(chan interface{})
+		returned := make(chan struct{})
+
+		go func() {
+			defer close(jobIn)
+			for val := range in {
+				select {
+				case jobIn <- val:
+				case <-returned:
+					st.mu.Lock()
+					st.unread++
+					st.mu.Unlock()
+				}
+			}
+			st.mu.Lock()
+			st.inClosed = true
+			st.mu.Unlock()
+		}()
+
+		// все записи стадии до возврата уже приняты этой горутиной, поэтому
+		// сигнал о возврате она увидит после них, а всё, что придёт позже, — поздние записи
+		returnedSignal := make(chan struct{})
+		tracked := proxies.add()
+		go func() {
+			if tracked {
+				defer proxies.wg.Done()
+			}
+			late := false
+			for {
+				select {
+				case val, ok := <-jobOut:
+					if !ok {
+						st.mu.Lock()
+						st.closedOut = true
+						st.mu.Unlock()
+						jobOut = nil
+						continue
+					}
+					if !late {
+						select {
+						case out <- val:
+						case <-stop:
+							st.mu.Lock()
+							st.stuck = true
+							st.mu.Unlock()
+							return
+						}
+						continue
+					}
+					st.mu.Lock()
+					st.late++
+					st.mu.Unlock()
+				case <-returnedSignal:
+					late = true
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					st.mu.Lock()
+					st.panicVal = r
+					st.mu.Unlock()
+				}
+			}()
+			fn(jobIn, jobOut)
+		}()
+
+		st.mu.Lock()
+		st.returned = true
+		st.mu.Unlock()
+		close(returned)
+		returnedSignal <- struct{}{}
+	}
+}
+
+// proxyGroup — WaitGroup горутин-посредников. Стадия может стартовать уже после возврата execute,
+// поэтому после wait группа новых горутин не принимает: Add нельзя вызывать одновременно с Wait.
+type proxyGroup struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	waiting bool
+}
+
+func (g *proxyGroup) add() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.waiting {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *proxyGroup) wait() {
+	g.mu.Lock()
+	g.waiting = true
+	g.mu.Unlock()
+	g.wg.Wait()
+}
+
+// waitGoroutines ждёт, пока горутин станет не больше limit, и возвращает их число
+// и признак того, что дождаться не удалось.
+func waitGoroutines(limit int, timeout time.Duration) (int, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= limit {
+			return n, false
+		}
+		if time.Now().After(deadline) {
+			return n, true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func goroutineStacks() string {
+	buf := make([]byte, 1<<20)
+	return string(buf[:runtime.Stack(buf, true)])
+}
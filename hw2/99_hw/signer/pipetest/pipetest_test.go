@@ -0,0 +1,145 @@
+package pipetest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type job = func(in, out chan interface{})
+
+// execute — минимальный ExecutePipeline: стадии соединены каналами, выход стадии закрывается после её возврата.
+func execute(jobs ...job) error {
+	wg := sync.WaitGroup{}
+	in := make(chan interface{})
+	close(in)
+	for _, fn := range jobs {
+		out := make(chan interface{})
+		wg.Add(1)
+		go func(fn job, in, out chan interface{}) {
+			defer wg.Done()
+			fn(in, out)
+			close(out)
+		}(fn, in, out)
+		in = out
+	}
+	for range in {
+	}
+	wg.Wait()
+	return nil
+}
+
+// stalled запускает стадии, но не читает выход последней и возвращает ошибку.
+func stalled(jobs ...job) error {
+	in := make(chan interface{})
+	close(in)
+	for _, fn := range jobs {
+		out := make(chan interface{})
+		go fn(in, out)
+		in = out
+	}
+	time.Sleep(10 * time.Millisecond)
+	return fmt.Errorf("gave up")
+}
+
+func source(in, out chan interface{}) {
+	for i := 0; i < 3; i++ {
+		out <- i
+	}
+}
+
+func forward(in, out chan interface{}) {
+	for val := range in {
+		out <- val
+	}
+}
+
+func TestCheck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	cases := []struct {
+		name     string
+		execute  func(jobs ...job) error
+		jobs     []job
+		expected string
+	}{
+		{
+			name: "clean",
+			jobs: []job{source, forward, forward},
+		},
+		{
+			name: "closes out",
+			jobs: []job{source, func(in, out chan interface{}) {
+				forward(in, out)
+				close(out)
+			}},
+			expected: "stage 1 closed its out channel",
+		},
+		{
+			name:     "unread input",
+			jobs:     []job{source, func(in, out chan interface{}) {}},
+			expected: "stage 1 returned with 3 unread input values",
+		},
+		{
+			name: "late send",
+			jobs: []job{source, func(in, out chan interface{}) {
+				go func() {
+					forward(in, out)
+					time.Sleep(10 * time.Millisecond)
+					out <- "late"
+				}()
+			}},
+			expected: "values after returning",
+		},
+		{
+			name: "leak",
+			jobs: []job{source, func(in, out chan interface{}) {
+				forward(in, out)
+				go func() {
+					<-release
+				}()
+			}},
+			expected: "goroutines leaked",
+		},
+		{
+			name:     "output not read",
+			execute:  stalled,
+			jobs:     []job{source},
+			expected: "stage 0: its output was never read",
+		},
+		{
+			name:     "error",
+			execute:  stalled,
+			jobs:     []job{func(in, out chan interface{}) {}},
+			expected: "pipeline returned error: gave up",
+		},
+	}
+
+	for _, item := range cases {
+		r := &recorder{}
+		if item.execute == nil {
+			item.execute = execute
+		}
+		Check(r, item.execute, item.jobs, WithLeakTimeout(100*time.Millisecond))
+		got := strings.Join(r.errors, "\n")
+		if item.expected == "" && got != "" {
+			t.Errorf("%s: unexpected errors:\n%s", item.name, got)
+		}
+		if item.expected != "" && !strings.Contains(got, item.expected) {
+			t.Errorf("%s: violation not reported\nGot: %s\nExpected: %s", item.name, got, item.expected)
+		}
+	}
+}
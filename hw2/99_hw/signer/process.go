@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// seqItem — результат обработки вместе с номером входного значения.
+type seqItem[T any] struct {
+	seq     int
+	val     T
+	start   time.Time
+	skipped bool // значение ушло в DeadLetter, результата нет
+}
+
+// processItems вызывает fn для каждого значения из in в отдельной горутине и пишет результат в out.
+// Число одновременно обрабатываемых значений ограничено cfg.workers через канал квот.
+// В режиме cfg.ordered результаты выходят в порядке входа: слот квоты освобождается только
+// после отправки результата, поэтому буфер перестановки не больше cfg.workers.
+// Значения, отправленные политикой повторов в DeadLetter, пропускаются.
+// Первая ошибка прекращает чтение входа, дожидается уже запущенных горутин и возвращается.
+func processItems[In, Out any](ctx context.Context, in <-chan In, out chan<- Out, cfg stageConfig,
+	fn func(ctx context.Context, val In) (Out, error)) error {
+	if cfg.retry != nil {
+		fn = retried(*cfg.retry, cfg.name, fn)
+	}
+	if cfg.checkpoint != nil {
+		fn = checkpointed(cfg.checkpoint, cfg.name, cfg.fingerprint, fn)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	var quotaCh chan struct{}
+	if cfg.workers > 0 {
+		quotaCh = make(chan struct{}, cfg.workers)
+	}
+
+	tracker := &stageTracker{name: cfg.name, obs: cfg.observer}
+
+	var results chan seqItem[Out]
+	reordered := make(chan struct{})
+	if cfg.ordered {
+		results = make(chan seqItem[Out])
+		go func() {
+			defer close(reordered)
+			if err := reorder(ctx, results, out, quotaCh, tracker); err != nil {
+				setErr(err)
+			}
+		}()
+	}
+
+	stageStart := time.Now()
+	seq := 0
+	wg := sync.WaitGroup{}
+	for ; ; seq++ {
+		if quotaCh != nil {
+			if err := send(ctx, quotaCh, struct{}{}); err != nil { // берём свободный слот
+				setErr(err)
+				break
+			}
+		}
+
+		val, err := recv(ctx, in)
+		if err != nil {
+			if err != errClosed {
+				setErr(err)
+			}
+			break
+		}
+
+		start := tracker.in()
+		wg.Add(1)
+		go func(seq int, val In) {
+			defer wg.Done()
+			if quotaCh != nil && !cfg.ordered {
+				defer func() { <-quotaCh }() // возвращаем слот
+			}
+			itemStart := time.Now()
+			res, err := fn(ctx, val)
+			if err == errDeadLettered {
+				cfg.logger.Warn("item sent to dead letter", "stage", cfg.name, "item", seq, "input", val)
+				if cfg.ordered {
+					if err := send(ctx, results, seqItem[Out]{seq: seq, skipped: true}); err != nil {
+						setErr(err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				cfg.logger.Error("item failed", "stage", cfg.name, "item", seq, "input", val, "error", err)
+				setErr(err)
+				return
+			}
+			cfg.logger.Debug("item processed", "stage", cfg.name, "item", seq, "input", val,
+				"duration", time.Since(itemStart))
+
+			tracker.ready()
+			if cfg.ordered {
+				err = send(ctx, results, seqItem[Out]{seq: seq, val: res, start: start})
+			} else if err = send(ctx, out, res); err == nil {
+				tracker.out(start)
+			}
+			if err != nil {
+				setErr(err)
+			}
+		}(seq, val)
+	}
+	wg.Wait()
+
+	if cfg.ordered {
+		close(results)
+		<-reordered
+	}
+
+	cfg.logger.Info("stage finished", "stage", cfg.name, "items", seq, "duration", time.Since(stageStart),
+		"error", firstErr)
+	return firstErr
+}
+
+// reorder отправляет результаты в out по порядку номеров, придерживая пришедшие раньше времени.
+// После отправки каждого результата освобождает слот в quotaCh, если он задан.
+func reorder[T any](ctx context.Context, results <-chan seqItem[T], out chan<- T, quotaCh chan struct{},
+	tracker *stageTracker) error {
+	pending := make(map[int]seqItem[T])
+	next := 0
+	for item := range results {
+		pending[item.seq] = item
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if !ready.skipped {
+				if err := send(ctx, out, ready.val); err != nil {
+					go drain(results)
+					return err
+				}
+				tracker.out(ready.start)
+			}
+			next++
+			if quotaCh != nil {
+				<-quotaCh // возвращаем слот
+			}
+		}
+	}
+	return nil
+}
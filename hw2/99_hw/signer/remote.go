@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// Удалённые стадии ходят по gRPC без protobuf: сообщения кодируются в JSON (content-subtype "json"),
+// а описание сервиса написано руками по образцу сгенерированного кода.
+const (
+	stageServiceName   = "signer.StageService"
+	stageProcessMethod = "/" + stageServiceName + "/Process"
+)
+
+type stageRequest struct {
+	Stage string          `json:"stage"`
+	Value json.RawMessage `json:"value"`
+}
+
+type stageResponse struct {
+	Value json.RawMessage `json:"value"`
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// StageServer отдаёт стадии по gRPC. Каждый вызов прогоняет через стадию одно значение
+// и ждёт ровно один результат, поэтому подходит для поэлементных стадий вроде SingleHash и MultiHash.
+type StageServer struct {
+	mu     sync.RWMutex
+	stages map[string]func(ctx context.Context, value json.RawMessage) (json.RawMessage, error)
+}
+
+func NewStageServer() *StageServer {
+	return &StageServer{
+		stages: make(map[string]func(ctx context.Context, value json.RawMessage) (json.RawMessage, error)),
+	}
+}
+
+// RegisterStage делает стадию s доступной на srv под именем name.
+func RegisterStage[In, Out any](srv *StageServer, name string, s Stage[In, Out]) {
+	p := NewPipeline(s)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.stages[name] = func(ctx context.Context, value json.RawMessage) (json.RawMessage, error) {
+		var val In
+		if err := json.Unmarshal(value, &val); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "decode value: %s", err)
+		}
+		results, err := p.Run(ctx, val)
+		if err != nil {
+			return nil, stageStatus(ctx, err)
+		}
+		if len(results) != 1 {
+			return nil, status.Errorf(codes.FailedPrecondition, "stage %s produced %d results, expected 1", name, len(results))
+		}
+		return json.Marshal(results[0])
+	}
+}
+
+// stageStatus переводит ошибку стадии в статус gRPC: ошибка на конкретном значении повторится
+// на любом воркере, поэтому она FailedPrecondition, а не Internal, и клиент её не повторяет.
+func stageStatus(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	var stageErr *StageError
+	if errors.As(err, &stageErr) && stageErr.Input != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Register добавляет сервис стадий в gRPC-сервер.
+func (srv *StageServer) Register(g *grpc.Server) {
+	g.RegisterService(&stageServiceDesc, srv)
+}
+
+func (srv *StageServer) process(ctx context.Context, req *stageRequest) (*stageResponse, error) {
+	srv.mu.RLock()
+	fn, ok := srv.stages[req.Stage]
+	srv.mu.RUnlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown stage %s", req.Stage)
+	}
+
+	value, err := fn(ctx, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &stageResponse{Value: value}, nil
+}
+
+type stageService interface {
+	process(ctx context.Context, req *stageRequest) (*stageResponse, error)
+}
+
+var stageServiceDesc = grpc.ServiceDesc{
+	ServiceName: stageServiceName,
+	HandlerType: (*stageService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Process",
+			Handler:    stageProcessHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "remote.go",
+}
+
+func stageProcessHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(stageRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(stageService).process(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: stageProcessMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(stageService).process(ctx, req.(*stageRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// Workers — набор соединений с удалёнными StageServer.
+// Значения раздаются по кругу, при сбое вызов повторяется на следующем адресе.
+type Workers struct {
+	conns   []*grpc.ClientConn
+	retries int
+	next    uint32
+}
+
+// DialWorkers подключается к addrs. retries — сколько раз повторить вызов на других адресах.
+func DialWorkers(addrs []string, retries int) (*Workers, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no worker addresses")
+	}
+	w := &Workers{retries: retries}
+	for _, addr := range addrs {
+		conn, err := grpc.NewClient(addr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodec{}.Name())),
+		)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("dial %s: %w", addr, err)
+		}
+		w.conns = append(w.conns, conn)
+	}
+	return w, nil
+}
+
+func (w *Workers) Close() error {
+	var firstErr error
+	for _, conn := range w.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (w *Workers) invoke(ctx context.Context, req *stageRequest) (*stageResponse, error) {
+	start := int(atomic.AddUint32(&w.next, 1))
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		conn := w.conns[(start+attempt)%len(w.conns)]
+		resp := &stageResponse{}
+		if err = conn.Invoke(ctx, stageProcessMethod, req, resp); err == nil {
+			return resp, nil
+		}
+		if !retryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// retryable отделяет сбои воркера от ошибок в самих данных, которые повторять бесполезно.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition, codes.Canceled:
+		return false
+	}
+	return true
+}
+
+// RemoteStage — стадия, которая обрабатывает каждое значение на одном из workers
+// стадией, зарегистрированной там под именем name. opts работают как у SingleHash/MultiHash.
+func RemoteStage[In, Out any](name string, workers *Workers, opts ...StageOption) Stage[In, Out] {
+	cfg := newStageConfig(append([]StageOption{WithName(name)}, opts...))
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		return processItems(ctx, in, out, cfg, func(ctx context.Context, val In) (Out, error) {
+			var res Out
+			value, err := json.Marshal(val)
+			if err != nil {
+				return res, ItemError(val, err)
+			}
+			resp, err := workers.invoke(ctx, &stageRequest{Stage: name, Value: value})
+			if err != nil {
+				return res, ItemError(val, err)
+			}
+			if err := json.Unmarshal(resp.Value, &res); err != nil {
+				return res, ItemError(val, fmt.Errorf("decode result: %w", err))
+			}
+			return res, nil
+		})
+	}
+}
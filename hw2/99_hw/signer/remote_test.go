@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startStageServer поднимает StageServer с быстрыми подписями на случайном локальном порту.
+func startStageServer(t *testing.T) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cant listen: %v", err)
+	}
+
+	h := NewHasher(WithChecksum(NewCRC32Signer("")), WithDigest(NewMD5Signer("")))
+	srv := NewStageServer()
+	RegisterStage(srv, "SingleHash", h.SingleHashStage())
+	RegisterStage(srv, "MultiHash", h.MultiHashStage())
+
+	g := grpc.NewServer()
+	srv.Register(g)
+	go g.Serve(lis)
+
+	return lis.Addr().String(), g.Stop
+}
+
+func TestRemoteStages(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+
+	addr1, stop1 := startStageServer(t)
+	defer stop1()
+	addr2, stop2 := startStageServer(t)
+	stop2() // второй воркер недоступен, вызовы должны уйти на первый
+
+	workers, err := DialWorkers([]string{addr1, addr2}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer workers.Close()
+
+	p := Then(Then(
+		NewPipeline(RemoteStage[int, string]("SingleHash", workers)),
+		RemoteStage[string, string]("MultiHash", workers)),
+		NewHasher().CombineResultsStage())
+
+	results, err := p.Run(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0] != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, testExpected)
+	}
+}
+
+func TestRemoteStageUnknown(t *testing.T) {
+	addr, stop := startStageServer(t)
+	defer stop()
+
+	workers, err := DialWorkers([]string{addr}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer workers.Close()
+
+	_, err = NewPipeline(RemoteStage[int, string]("Unknown", workers)).Run(context.Background(), 1)
+	if err == nil {
+		t.Errorf("expected error for unknown stage")
+	}
+}
+
+func TestRemoteStageItemErrorNotRetried(t *testing.T) {
+	var calls int32
+	failing := Stage[int, string](func(ctx context.Context, in <-chan int, out chan<- string) error {
+		for val := range in {
+			atomic.AddInt32(&calls, 1)
+			return ItemError(val, fmt.Errorf("bad value"))
+		}
+		return nil
+	})
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("cant listen: %v", err)
+		}
+		srv := NewStageServer()
+		RegisterStage(srv, "Failing", failing)
+		g := grpc.NewServer()
+		srv.Register(g)
+		go g.Serve(lis)
+		defer g.Stop()
+		addrs = append(addrs, lis.Addr().String())
+	}
+
+	workers, err := DialWorkers(addrs, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer workers.Close()
+
+	_, err = NewPipeline(RemoteStage[int, string]("Failing", workers)).Run(context.Background(), 1)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("item error retried: %d calls, expected 1", calls)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy описывает, как стадия обрабатывает сбои на отдельных значениях.
+type RetryPolicy struct {
+	Timeout    time.Duration // ограничение на одну попытку, 0 — без ограничения
+	MaxRetries int           // сколько раз повторить после первой неудачной попытки
+	Backoff    time.Duration // пауза перед первым повтором, дальше удваивается
+	MaxBackoff time.Duration // верхняя граница паузы, 0 — без границы
+	Jitter     float64       // случайный разброс паузы в долях от неё, от 0 до 1
+
+	// DeadLetter получает значения, не обработанные за все попытки, и стадия продолжает работу.
+	// Если канал не задан, такая ошибка останавливает конвейер. Канал нужно вычитывать.
+	DeadLetter chan<- DeadLetter
+}
+
+// DeadLetter — значение, которое стадия не смогла обработать.
+type DeadLetter struct {
+	Stage    string
+	Input    interface{}
+	Err      error
+	Attempts int
+}
+
+// errDeadLettered — значение ушло в DeadLetter, стадия пропускает его и работает дальше.
+var errDeadLettered = errors.New("item sent to dead letter")
+
+// retried повторяет fn по правилам policy. Попытка с таймаутом выполняется в отдельной горутине,
+// чтобы зависший вызов, не смотрящий на ctx, не держал значение: её результат просто выбрасывается.
+func retried[In, Out any](policy RetryPolicy, stage string,
+	fn func(ctx context.Context, val In) (Out, error)) func(ctx context.Context, val In) (Out, error) {
+	return func(ctx context.Context, val In) (Out, error) {
+		var (
+			res Out
+			err error
+		)
+		attempts := 0
+		for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+			if attempt > 0 {
+				if err := sleepContext(ctx, policy.delay(attempt)); err != nil {
+					return res, err
+				}
+			}
+			attempts++
+			if res, err = attemptWithTimeout(ctx, policy.Timeout, val, fn); err == nil {
+				return res, nil
+			}
+			if ctx.Err() != nil {
+				return res, err
+			}
+		}
+
+		if policy.DeadLetter == nil {
+			return res, err
+		}
+		letter := DeadLetter{Stage: stage, Input: val, Err: err, Attempts: attempts}
+		if err := send(ctx, policy.DeadLetter, letter); err != nil {
+			return res, err
+		}
+		return res, errDeadLettered
+	}
+}
+
+func attemptWithTimeout[In, Out any](ctx context.Context, timeout time.Duration, val In,
+	fn func(ctx context.Context, val In) (Out, error)) (Out, error) {
+	if timeout <= 0 {
+		return fn(ctx, val)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		res Out
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := fn(ctx, val)
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		var zero Out
+		return zero, ItemError(val, fmt.Errorf("attempt timed out after %s: %w", timeout, ctx.Err()))
+	}
+}
+
+// delay возвращает паузу перед повтором номер attempt (начиная с 1).
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
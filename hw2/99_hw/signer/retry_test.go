@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+func runRetried(t *testing.T, n int, opts []StageOption, fn func(ctx context.Context, val int) (int, error)) ([]int, error) {
+	t.Helper()
+	in := make(chan int)
+	go func() {
+		for i := 0; i < n; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	out := make(chan int, n)
+	err := processItems(context.Background(), in, out, newStageConfig(opts), fn)
+	close(out)
+
+	var res []int
+	for val := range out {
+		res = append(res, val)
+	}
+	return res, err
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = map[int]int{}
+	)
+	policy := RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	res, err := runRetried(t, 5, []StageOption{WithRetry(policy)}, func(ctx context.Context, val int) (int, error) {
+		mu.Lock()
+		calls[val]++
+		attempt := calls[val]
+		mu.Unlock()
+		if attempt <= 2 {
+			return 0, errFlaky
+		}
+		return val, nil
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(res) != 5 {
+		t.Errorf("not all values processed: %d", len(res))
+	}
+	for val, n := range calls {
+		if n != 3 {
+			t.Errorf("wrong attempts for %d\nGot: %d\nExpected: 3", val, n)
+		}
+	}
+}
+
+func TestRetryExhaustedStopsStage(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 1}
+	_, err := runRetried(t, 3, []StageOption{WithRetry(policy)}, func(ctx context.Context, val int) (int, error) {
+		return 0, errFlaky
+	})
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("wrong error\nGot: %v\nExpected: %v", err, errFlaky)
+	}
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		letters := make(chan DeadLetter, 10)
+		opts := []StageOption{WithName("Stage"), WithWorkers(2), WithRetry(RetryPolicy{
+			MaxRetries: 1,
+			DeadLetter: letters,
+		})}
+		if ordered {
+			opts = append(opts, WithOrdered())
+		}
+		res, err := runRetried(t, 6, opts, func(ctx context.Context, val int) (int, error) {
+			if val%3 == 1 {
+				return 0, errFlaky
+			}
+			return val, nil
+		})
+		close(letters)
+
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(res) != 4 {
+			t.Errorf("wrong result count (ordered=%v)\nGot: %d\nExpected: 4", ordered, len(res))
+		}
+		if ordered && !equalInts(res, []int{0, 2, 3, 5}) {
+			t.Errorf("wrong order\nGot: %v\nExpected: %v", res, []int{0, 2, 3, 5})
+		}
+
+		dead := 0
+		for letter := range letters {
+			dead++
+			if letter.Stage != "Stage" || letter.Attempts != 2 || !errors.Is(letter.Err, errFlaky) {
+				t.Errorf("unexpected dead letter: %+v", letter)
+			}
+			if val, _ := letter.Input.(int); val%3 != 1 {
+				t.Errorf("wrong value in dead letter: %v", letter.Input)
+			}
+		}
+		if dead != 2 {
+			t.Errorf("wrong dead letter count\nGot: %d\nExpected: 2", dead)
+		}
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		stuck = true
+	)
+	block := make(chan struct{})
+	defer close(block)
+
+	policy := RetryPolicy{Timeout: 20 * time.Millisecond, MaxRetries: 1}
+	start := time.Now()
+	res, err := runRetried(t, 1, []StageOption{WithRetry(policy)}, func(ctx context.Context, val int) (int, error) {
+		mu.Lock()
+		first := stuck
+		stuck = false
+		mu.Unlock()
+		if first {
+			<-block // зависает, не глядя на ctx
+		}
+		return val, nil
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(res) != 1 {
+		t.Errorf("value not processed after timeout: %v", res)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stuck attempt was not abandoned: %v", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, exp := range expected {
+		if got := policy.delay(i + 1); got != exp*time.Millisecond {
+			t.Errorf("wrong delay for attempt %d\nGot: %v\nExpected: %v", i+1, got, exp*time.Millisecond)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
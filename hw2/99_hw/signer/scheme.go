@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortOrder — порядок, в котором CombineResults склеивает результаты.
+type SortOrder int
+
+const (
+	SortLexical SortOrder = iota // как строки, по умолчанию
+	SortNumeric                  // как неотрицательные целые числа произвольной длины
+	SortNone                     // в порядке поступления
+)
+
+// WithRounds задаёт число раундов MultiHash (по умолчанию 6). Меньше одного раунда не бывает:
+// n < 1 считается за 1.
+func WithRounds(n int) HasherOption {
+	return func(h *Hasher) {
+		if n < 1 {
+			n = 1
+		}
+		h.rounds = n
+	}
+}
+
+// WithSingleSeparator задаёт разделитель двух половин SingleHash (по умолчанию "~").
+func WithSingleSeparator(sep string) HasherOption {
+	return func(h *Hasher) {
+		h.singleSep = sep
+	}
+}
+
+// WithCombineSeparator задаёт разделитель результатов в CombineResults (по умолчанию "_").
+func WithCombineSeparator(sep string) HasherOption {
+	return func(h *Hasher) {
+		h.combineSep = sep
+	}
+}
+
+// WithSortOrder задаёт порядок результатов в CombineResults (по умолчанию SortLexical).
+func WithSortOrder(order SortOrder) HasherOption {
+	return func(h *Hasher) {
+		h.sortOrder = order
+	}
+}
+
+func sortResults(results []string, order SortOrder) {
+	switch order {
+	case SortLexical:
+		sort.Strings(results)
+	case SortNumeric:
+		sort.SliceStable(results, func(i, j int) bool {
+			return lessNumeric(results[i], results[j])
+		})
+	}
+}
+
+// lessNumeric сравнивает строки из цифр как числа, не переводя их в int:
+// результаты MultiHash не помещаются ни в один целый тип. Остальные строки сравниваются как есть.
+func lessNumeric(a, b string) bool {
+	if !isDigits(a) || !isDigits(b) {
+		return a < b
+	}
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
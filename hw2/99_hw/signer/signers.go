@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Signer считает подпись строки. Реализации должны быть безопасны для конкурентного вызова.
+type Signer interface {
+	Sign(ctx context.Context, data string) (string, error)
+}
+
+// SignerFunc позволяет использовать обычную функцию как Signer.
+type SignerFunc func(ctx context.Context, data string) (string, error)
+
+func (f SignerFunc) Sign(ctx context.Context, data string) (string, error) {
+	return f(ctx, data)
+}
+
+// NewCRC32Signer возвращает crc32 (IEEE) от data+salt в десятичном виде, как DataSignerCrc32.
+func NewCRC32Signer(salt string) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		crcH := crc32.ChecksumIEEE([]byte(data + salt))
+		return strconv.FormatUint(uint64(crcH), 10), nil
+	})
+}
+
+// NewMD5Signer возвращает md5 от data+salt в hex, как DataSignerMd5, но без ограничения на параллельность.
+func NewMD5Signer(salt string) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return fmt.Sprintf("%x", md5.Sum([]byte(data+salt))), nil
+	})
+}
+
+// NewSHA256Signer возвращает sha256 от data+salt в hex.
+func NewSHA256Signer(salt string) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(data+salt))), nil
+	})
+}
+
+// NewXXHashSigner возвращает xxhash64 от data+salt в десятичном виде.
+func NewXXHashSigner(salt string) Signer {
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return strconv.FormatUint(xxhash.Sum64String(data+salt), 10), nil
+	})
+}
+
+// NewHMACSigner возвращает HMAC-SHA256 от data с ключом key в hex.
+func NewHMACSigner(key []byte) Signer {
+	key = append([]byte(nil), key...)
+	return SignerFunc(func(ctx context.Context, data string) (string, error) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	})
+}
+
+// globalCrc32 и globalMd5 обращаются к DataSignerCrc32 и DataSignerMd5 в момент вызова,
+// поэтому подмена этих переменных (как в тестах) продолжает работать.
+var (
+	globalCrc32 = SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return DataSignerCrc32(data), nil
+	})
+	globalMd5 = SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return DataSignerMd5(data), nil
+	})
+)
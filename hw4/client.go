@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -28,10 +29,14 @@ type User struct {
 type SearchResponse struct {
 	Users    []User
 	NextPage bool
+	// курсор следующей страницы, передаётся в SearchRequest.Cursor; пустой, если страница последняя
+	NextCursor string
 }
 
 type SearchErrorResponse struct {
 	Error string
+	// номер символа в фильтре (с 1), на котором сломался разбор, 0 — ошибка не в фильтре
+	Position int `json:",omitempty"`
 }
 
 const (
@@ -46,8 +51,13 @@ type SearchRequest struct {
 	Limit      int
 	Offset     int    // Можно учесть после сортировки
 	Query      string // подстрока в 1 из полей
-	OrderField string
-	OrderBy    int
+	Text       string // слова из Name или About: AND, OR, "фраза" без учёта регистра; OrderField "relevance" — по BM25
+	OrderField string // поле или список полей с направлением: "age:desc,name:asc"
+	OrderBy    int    // направление для полей, у которых оно не указано
+	Filter     Filter // условие на поля, см. Field
+	// курсор из SearchResponse.NextCursor, с ним Offset не учитывается.
+	// Остальные параметры должны быть те же, что в запросе, на который пришёл курсор
+	Cursor string
 }
 
 type SearchClient struct {
@@ -72,14 +82,24 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		return nil, fmt.Errorf("offset must be > 0")
 	}
 
-	//нужно для получения следующей записи, на основе которой мы скажем - можно показать переключатель следующей страницы или нет
+	// просим на одну запись больше: если она пришла, есть следующая страница
 	req.Limit++
 
 	searcherParams.Add("limit", strconv.Itoa(req.Limit))
+	searcherParams.Add("lookahead", "1")
 	searcherParams.Add("offset", strconv.Itoa(req.Offset))
 	searcherParams.Add("query", req.Query)
+	if req.Text != "" {
+		searcherParams.Add("text", req.Text)
+	}
 	searcherParams.Add("order_field", req.OrderField)
 	searcherParams.Add("order_by", strconv.Itoa(req.OrderBy))
+	if filter := req.Filter.String(); filter != "" {
+		searcherParams.Add("filter", filter)
+	}
+	if req.Cursor != "" {
+		searcherParams.Add("cursor", req.Cursor)
+	}
 
 	searcherReq, err := http.NewRequest("GET", srv.URL+"?"+searcherParams.Encode(), nil)
 	searcherReq.Header.Add("AccessToken", srv.AccessToken)
@@ -108,6 +128,12 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		if errResp.Error == ErrorBadOrderField {
 			return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
 		}
+		if errResp.Position > 0 {
+			return nil, fmt.Errorf("bad filter %q: %s", req.Filter, errResp.Error)
+		}
+		if req.Cursor != "" && strings.HasPrefix(errResp.Error, "cursor: ") {
+			return nil, fmt.Errorf("bad cursor: %s", strings.TrimPrefix(errResp.Error, "cursor: "))
+		}
 		//return nil, fmt.Errorf("unknown bad request error: %s", errResp.Error)
 	}
 
@@ -117,12 +143,15 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		return nil, fmt.Errorf("cant unpack result json: %s", err)
 	}
 
-	result := SearchResponse{}
+	// сервер отдаёт курсор, только если после страницы есть ещё записи;
+	// сервер без курсоров его не пришлёт, тогда о следующей странице говорит лишняя запись
+	result := SearchResponse{Users: data, NextCursor: resp.Header.Get("X-Next-Cursor")}
 	if len(data) == req.Limit {
 		result.NextPage = true
 		result.Users = data[0 : len(data)-1]
-	} else {
-		result.Users = data[0:len(data)]
+	}
+	if result.NextCursor != "" {
+		result.NextPage = true
 	}
 
 	return &result, err
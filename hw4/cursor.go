@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// cursorHeader — заголовок ответа с курсором следующей страницы, пустой на последней странице.
+const cursorHeader = "X-Next-Cursor"
+
+var errBadCursor = errors.New("invalid token")
+
+// cursorSecret подписывает курсоры. Задаётся флагом -cursor-secret, иначе случайный:
+// тогда курсоры перестают приниматься после перезапуска сервера.
+var cursorSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// searchCursor — содержимое курсора: параметры запроса, для которых он выдан, и последняя отданная запись.
+type searchCursor struct {
+	Order  string  `json:"o"` // orderSpec.String()
+	Query  string  `json:"q,omitempty"`
+	Text   string  `json:"t,omitempty"`
+	Filter string  `json:"fl,omitempty"`
+	After  sortKey `json:"a"`
+}
+
+// encode возвращает токен вида base64(json).base64(hmac).
+func (c searchCursor) encode(secret []byte) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeCursor(token string, secret []byte) (searchCursor, error) {
+	var c searchCursor
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return c, errBadCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, errBadCursor
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return c, errBadCursor
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return c, errBadCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, errBadCursor
+	}
+	return c, nil
+}
+
+// startAfter возвращает номер первой записи в отсортированном по spec persons, которая идёт после курсора.
+func startAfter(persons []Person, spec orderSpec, c searchCursor, scores map[int]float64) int {
+	return sort.Search(len(persons), func(i int) bool {
+		return spec.before(c.After, spec.keyOf(&persons[i], scores))
+	})
+}
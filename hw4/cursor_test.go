@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursorToken(t *testing.T) {
+	secret := []byte("secret")
+	c := searchCursor{Order: "age:asc", Query: "ann", After: sortKey{Values: []sortValue{{Num: 30}}, Id: 7}}
+	token := c.encode(secret)
+
+	got, err := decodeCursor(token, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("wrong cursor\nGot: %+v\nExpected: %+v", got, c)
+	}
+
+	for _, bad := range []string{"", "abc", token + "x", "e30." + strings.SplitN(token, ".", 2)[1]} {
+		if _, err := decodeCursor(bad, secret); err != errBadCursor {
+			t.Errorf("%q: expected errBadCursor, got %v", bad, err)
+		}
+	}
+	if _, err := decodeCursor(token, []byte("other")); err != errBadCursor {
+		t.Errorf("expected errBadCursor for foreign secret, got %v", err)
+	}
+}
+
+func TestSearchServerCursor(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(
+		Person{Id: 1, FirstName: "Ann", Age: 30},
+		Person{Id: 2, FirstName: "Bob", Age: 20},
+		Person{Id: 3, FirstName: "Eve", Age: 30},
+		Person{Id: 4, FirstName: "Kim", Age: 40},
+		Person{Id: 5, FirstName: "Leo", Age: 30},
+	)
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	req := SearchRequest{Limit: 2, OrderField: fieldAge, OrderBy: OrderByAsc}
+	resp, err := srv.FindUsers(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := func(users []User) []int {
+		var res []int
+		for _, u := range users {
+			res = append(res, u.Id)
+		}
+		return res
+	}
+	if got := ids(resp.Users); !reflect.DeepEqual(got, []int{2, 1}) || !resp.NextPage {
+		t.Fatalf("wrong first page\nGot: %v %v\nExpected: [2 1] true", got, resp.NextPage)
+	}
+
+	// запись перед курсором добавили, а последнюю отданную удалили — следующая страница не сдвигается
+	dataset = testDataset(
+		Person{Id: 0, FirstName: "Ada", Age: 10},
+		Person{Id: 3, FirstName: "Eve", Age: 30},
+		Person{Id: 4, FirstName: "Kim", Age: 40},
+		Person{Id: 5, FirstName: "Leo", Age: 30},
+	)
+	req.Cursor = resp.NextCursor
+	resp, err = srv.FindUsers(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ids(resp.Users); !reflect.DeepEqual(got, []int{3, 5}) || !resp.NextPage {
+		t.Errorf("wrong second page\nGot: %v %v\nExpected: [3 5] true", got, resp.NextPage)
+	}
+
+	req.Cursor = resp.NextCursor
+	resp, err = srv.FindUsers(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ids(resp.Users); !reflect.DeepEqual(got, []int{4}) || resp.NextPage || resp.NextCursor != "" {
+		t.Errorf("wrong last page\nGot: %v %v %q\nExpected: [4] false", got, resp.NextPage, resp.NextCursor)
+	}
+
+	other := SearchRequest{Limit: 2, OrderField: fieldName, OrderBy: OrderByAsc, Cursor: req.Cursor}
+	if _, err := srv.FindUsers(other); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+	other = req
+	other.Cursor = "forged.token"
+	if _, err := srv.FindUsers(other); err == nil || err.Error() != "bad cursor: invalid token" {
+		t.Errorf("expected bad cursor error, got %v", err)
+	}
+}
+
+func TestFindUsersPageBoundary(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(
+		Person{Id: 1, FirstName: "Ann", Age: 30},
+		Person{Id: 2, FirstName: "Bob", Age: 20},
+		Person{Id: 3, FirstName: "Eve", Age: 40},
+	)
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	// Limit: 0 — пустая страница, а не все записи
+	resp, err := srv.FindUsers(SearchRequest{Limit: 0, OrderField: "id", OrderBy: OrderByAsc})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 0 || !resp.NextPage {
+		t.Errorf("wrong Limit: 0 page\nGot: %d users, %v\nExpected: 0 users, true", len(resp.Users), resp.NextPage)
+	}
+
+	cases := []struct {
+		limit    int
+		users    int
+		nextPage bool
+	}{
+		{2, 2, true},
+		{3, 3, false},
+		{4, 3, false},
+	}
+	for _, c := range cases {
+		resp, err := srv.FindUsers(SearchRequest{Limit: c.limit, OrderField: "id", OrderBy: OrderByAsc})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.Users) != c.users || resp.NextPage != c.nextPage || (resp.NextCursor != "") != c.nextPage {
+			t.Errorf("limit %d\nGot: %d users, %v %q\nExpected: %d users, %v", c.limit, len(resp.Users), resp.NextPage, resp.NextCursor, c.users, c.nextPage)
+		}
+	}
+
+	// курсор после страницы с лишней записью указывает на следующую запись, а не через одну
+	resp, _ = srv.FindUsers(SearchRequest{Limit: 1, OrderField: "id", OrderBy: OrderByAsc})
+	resp, err = srv.FindUsers(SearchRequest{Limit: 1, OrderField: "id", OrderBy: OrderByAsc, Cursor: resp.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Id != 2 {
+		t.Errorf("wrong page after cursor\nGot: %+v\nExpected: user 2", resp.Users)
+	}
+
+	// сервер без курсоров: о следующей странице говорит лишняя запись
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		SearchServer(rec, r)
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer plain.Close()
+	srv.URL = plain.URL
+	for _, c := range cases {
+		resp, err := srv.FindUsers(SearchRequest{Limit: c.limit, OrderField: "id", OrderBy: OrderByAsc})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.Users) != c.users || resp.NextPage != c.nextPage {
+			t.Errorf("limit %d without cursor header\nGot: %d users, %v\nExpected: %d users, %v", c.limit, len(resp.Users), resp.NextPage, c.users, c.nextPage)
+		}
+	}
+}
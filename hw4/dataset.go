@@ -0,0 +1,521 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Loader читает список Person из r в своём формате.
+type Loader interface {
+	Load(r io.Reader) ([]Person, error)
+}
+
+// LoaderFunc позволяет использовать функцию как Loader.
+type LoaderFunc func(r io.Reader) ([]Person, error)
+
+func (f LoaderFunc) Load(r io.Reader) ([]Person, error) {
+	return f(r)
+}
+
+// personRecord — запись в JSON, JSON lines и CSV, поля называются так же, как в dataset.xml.
+type personRecord struct {
+	Id        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Age       int    `json:"age"`
+	About     string `json:"about"`
+	Gender    string `json:"gender"`
+}
+
+func recordOf(p Person) personRecord {
+	return personRecord{Id: p.Id, FirstName: p.FirstName, LastName: p.LastName, Age: p.Age, About: p.About, Gender: p.Gender}
+}
+
+func (rec personRecord) person() Person {
+	return Person{
+		FirstName: rec.FirstName,
+		LastName:  rec.LastName,
+		Id:        rec.Id,
+		Age:       rec.Age,
+		About:     rec.About,
+		Gender:    rec.Gender,
+	}
+}
+
+// xmlElement — элемент строки dataset.xml, для которого нет поля в Person.
+type xmlElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// xmlRow — строка dataset.xml при чтении: поля Person и всё остальное.
+type xmlRow struct {
+	Person
+	Other []xmlElement `xml:",any"`
+}
+
+// XMLLoader читает формат dataset.xml: <root><row>...</row></root>.
+var XMLLoader = LoaderFunc(func(r io.Reader) ([]Person, error) {
+	var data struct {
+		Rows []xmlRow `xml:"row"`
+	}
+	if err := xml.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode xml: %w", err)
+	}
+	persons := make([]Person, 0, len(data.Rows))
+	for _, row := range data.Rows {
+		extra := strings.Builder{}
+		for _, el := range row.Other {
+			raw, err := xml.Marshal(el)
+			if err != nil {
+				return nil, fmt.Errorf("decode xml: row %d: %w", row.Id, err)
+			}
+			extra.WriteString("\n    ")
+			extra.Write(raw)
+		}
+		row.Person.Extra = extra.String()
+		persons = append(persons, row.Person)
+	}
+	return persons, nil
+})
+
+// JSONLoader читает JSON-массив записей.
+var JSONLoader = LoaderFunc(func(r io.Reader) ([]Person, error) {
+	var records []personRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	persons := make([]Person, 0, len(records))
+	for _, rec := range records {
+		persons = append(persons, rec.person())
+	}
+	return persons, nil
+})
+
+// JSONLinesLoader читает по одной JSON-записи на строку.
+var JSONLinesLoader = LoaderFunc(func(r io.Reader) ([]Person, error) {
+	var persons []Person
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var rec personRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return persons, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode json lines: record %d: %w", line, err)
+		}
+		persons = append(persons, rec.person())
+	}
+})
+
+// CSVLoader читает CSV с заголовком. Columns сопоставляет полю записи (id, first_name, last_name,
+// age, about, gender) имя колонки в файле; поле без сопоставления ищется в колонке с тем же именем.
+type CSVLoader struct {
+	Columns map[string]string
+}
+
+var csvFields = []string{"id", "first_name", "last_name", "age", "about", "gender"}
+
+func (l CSVLoader) Load(r io.Reader) ([]Person, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	// номер колонки для каждого поля, -1 — колонки нет
+	index := make(map[string]int, len(csvFields))
+	for _, field := range csvFields {
+		column := field
+		if mapped, ok := l.Columns[field]; ok {
+			column = mapped
+		}
+		index[field] = -1
+		for i, name := range header {
+			if strings.TrimSpace(name) == column {
+				index[field] = i
+			}
+		}
+	}
+	for field, column := range l.Columns {
+		if i, ok := index[field]; !ok || i < 0 {
+			return nil, fmt.Errorf("csv: column %q for field %q not found", column, field)
+		}
+	}
+
+	var persons []Person
+	for record := 1; ; record++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return persons, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+
+		value := func(field string) string {
+			if i := index[field]; i >= 0 {
+				return row[i]
+			}
+			return ""
+		}
+		number := func(field string) (int, error) {
+			val := strings.TrimSpace(value(field))
+			if val == "" {
+				return 0, nil
+			}
+			num, err := strconv.Atoi(val)
+			if err != nil {
+				return 0, fmt.Errorf("csv: record %d: bad %s %q", record, field, val)
+			}
+			return num, nil
+		}
+
+		rec := personRecord{
+			FirstName: value("first_name"),
+			LastName:  value("last_name"),
+			About:     value("about"),
+			Gender:    value("gender"),
+		}
+		if rec.Id, err = number("id"); err != nil {
+			return nil, err
+		}
+		if rec.Age, err = number("age"); err != nil {
+			return nil, err
+		}
+		persons = append(persons, rec.person())
+	}
+}
+
+// ParseColumns разбирает сопоставление колонок CSV вида "id=ID,first_name=First Name".
+func ParseColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if spec == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad column mapping %q: expected field=column", pair)
+		}
+		field := strings.TrimSpace(parts[0])
+		known := false
+		for _, name := range csvFields {
+			known = known || name == field
+		}
+		if !known {
+			return nil, fmt.Errorf("bad column mapping %q: unknown field %q", pair, field)
+		}
+		columns[field] = strings.TrimSpace(parts[1])
+	}
+	return columns, nil
+}
+
+// LoaderFor выбирает Loader по формату ("xml", "json", "jsonl", "csv"), а при пустом формате —
+// по расширению path. columns используется только для CSV.
+func LoaderFor(path, format string, columns map[string]string) (Loader, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch format {
+	case "xml":
+		return XMLLoader, nil
+	case "json":
+		return JSONLoader, nil
+	case "jsonl", "ndjson":
+		return JSONLinesLoader, nil
+	case "csv":
+		return CSVLoader{Columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("unknown dataset format %q", format)
+	}
+}
+
+// Saver записывает persons в w в своём формате.
+type Saver interface {
+	Save(w io.Writer, persons []Person) error
+}
+
+// SaverFunc позволяет использовать функцию как Saver.
+type SaverFunc func(w io.Writer, persons []Person) error
+
+func (f SaverFunc) Save(w io.Writer, persons []Person) error {
+	return f(w, persons)
+}
+
+// XMLSaver пишет в формате dataset.xml. Элементы строк, которых нет в Person, берутся из Extra,
+// поэтому прочитанный XMLLoader файл сохраняется без потерь.
+var XMLSaver = SaverFunc(func(w io.Writer, persons []Person) error {
+	type row struct {
+		Person
+		Extra string `xml:",innerxml"`
+	}
+	data := struct {
+		XMLName xml.Name `xml:"root"`
+		Rows    []row    `xml:"row"`
+	}{Rows: make([]row, 0, len(persons))}
+	for _, p := range persons {
+		data.Rows = append(data.Rows, row{Person: p, Extra: p.Extra})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("encode xml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+})
+
+// JSONSaver пишет JSON-массив записей.
+var JSONSaver = SaverFunc(func(w io.Writer, persons []Person) error {
+	records := make([]personRecord, 0, len(persons))
+	for _, p := range persons {
+		records = append(records, recordOf(p))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(records); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+})
+
+// JSONLinesSaver пишет по одной JSON-записи на строку.
+var JSONLinesSaver = SaverFunc(func(w io.Writer, persons []Person) error {
+	enc := json.NewEncoder(w)
+	for _, p := range persons {
+		if err := enc.Encode(recordOf(p)); err != nil {
+			return fmt.Errorf("encode json lines: %w", err)
+		}
+	}
+	return nil
+})
+
+// SaverFor выбирает Saver так же, как LoaderFor. CSV сохранять нельзя: сопоставление колонок
+// не описывает остальные колонки файла.
+func SaverFor(path, format string) (Saver, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch format {
+	case "xml":
+		return XMLSaver, nil
+	case "json":
+		return JSONSaver, nil
+	case "jsonl", "ndjson":
+		return JSONLinesSaver, nil
+	default:
+		return nil, fmt.Errorf("saving in format %q is not supported", format)
+	}
+}
+
+var (
+	ErrPersonNotFound = errors.New("person not found")
+	ErrPersonExists   = errors.New("person already exists")
+)
+
+// Dataset — загруженные записи, которые можно перечитать из источника, не останавливая сервер.
+type Dataset struct {
+	// writeMu не даёт изменениям и Reload перетереть друг друга, mu защищает подмену данных
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	path    string
+	loader  Loader
+	saver   Saver
+	persons []Person
+	index   *Index
+}
+
+// NewDataset создаёт Dataset, который читает path через loader. Данные загружаются вызовом Reload.
+func NewDataset(path string, loader Loader) *Dataset {
+	return &Dataset{path: path, loader: loader, index: NewIndex(nil)}
+}
+
+// WriteThrough включает сохранение в источник через saver после каждого изменения.
+// Вызывается до того, как Dataset начнут использовать.
+func (d *Dataset) WriteThrough(saver Saver) {
+	d.saver = saver
+}
+
+// Reload перечитывает источник. При ошибке остаются прежние данные.
+func (d *Dataset) Reload() error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	file, err := os.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("open dataset: %w", err)
+	}
+	defer file.Close()
+
+	persons, err := d.loader.Load(file)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", d.path, err)
+	}
+	d.set(persons)
+	return nil
+}
+
+// set заполняет Name и подменяет записи вместе с индексом.
+func (d *Dataset) set(persons []Person) {
+	for i, person := range persons {
+		persons[i].Name = fmt.Sprintf("%s %s", person.FirstName, person.LastName)
+	}
+	index := NewIndex(persons)
+
+	d.mu.Lock()
+	d.persons = persons
+	d.index = index
+	d.mu.Unlock()
+}
+
+// Create добавляет запись. При p.Id < 0 назначается id на 1 больше наибольшего.
+func (d *Dataset) Create(p Person) (Person, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	persons := d.Persons()
+	maxId := -1
+	for _, person := range persons {
+		if person.Id == p.Id {
+			return Person{}, ErrPersonExists
+		}
+		if person.Id > maxId {
+			maxId = person.Id
+		}
+	}
+	if p.Id < 0 {
+		p.Id = maxId + 1
+	}
+	updated := append(append(make([]Person, 0, len(persons)+1), persons...), p)
+	if err := d.commit(updated); err != nil {
+		return Person{}, err
+	}
+	return updated[len(updated)-1], nil
+}
+
+// Update меняет запись id функцией fn. Ошибка fn отменяет изменение, id менять нельзя.
+func (d *Dataset) Update(id int, fn func(p *Person) error) (Person, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	persons := d.Persons()
+	at := d.find(persons, id)
+	if at < 0 {
+		return Person{}, ErrPersonNotFound
+	}
+	updated := append([]Person(nil), persons...)
+	if err := fn(&updated[at]); err != nil {
+		return Person{}, err
+	}
+	if updated[at].Id != id {
+		return Person{}, fmt.Errorf("id of person %d can not be changed", id)
+	}
+	if err := d.commit(updated); err != nil {
+		return Person{}, err
+	}
+	return updated[at], nil
+}
+
+// Delete удаляет запись id и возвращает её.
+func (d *Dataset) Delete(id int) (Person, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	persons := d.Persons()
+	at := d.find(persons, id)
+	if at < 0 {
+		return Person{}, ErrPersonNotFound
+	}
+	updated := append(append(make([]Person, 0, len(persons)-1), persons[:at]...), persons[at+1:]...)
+	if err := d.commit(updated); err != nil {
+		return Person{}, err
+	}
+	return persons[at], nil
+}
+
+func (d *Dataset) find(persons []Person, id int) int {
+	for i := range persons {
+		if persons[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// commit сохраняет persons в источник, если включён WriteThrough, и подменяет ими текущие записи.
+// Вызывается под writeMu. Если сохранить не удалось, остаются прежние данные.
+func (d *Dataset) commit(persons []Person) error {
+	if d.saver != nil {
+		if err := d.save(persons); err != nil {
+			return err
+		}
+	}
+	d.set(persons)
+	return nil
+}
+
+// save пишет во временный файл рядом с источником и подменяет им источник, чтобы файл не остался недописанным.
+func (d *Dataset) save(persons []Person) error {
+	file, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save dataset: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if info, err := os.Stat(d.path); err == nil {
+		_ = file.Chmod(info.Mode())
+	}
+
+	if err := d.saver.Save(file, persons); err != nil {
+		file.Close()
+		return fmt.Errorf("save %s: %w", d.path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("save %s: %w", d.path, err)
+	}
+	if err := os.Rename(file.Name(), d.path); err != nil {
+		return fmt.Errorf("save dataset: %w", err)
+	}
+	return nil
+}
+
+// Persons возвращает текущие записи. Срез нельзя менять: Reload подменяет его целиком.
+func (d *Dataset) Persons() []Person {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.persons
+}
+
+// Search возвращает копии записей, подходящих под query, по убыванию релевантности вместе с оценками,
+// см. Index.Search. Пустой запрос возвращает все записи в исходном порядке и нулевые оценки.
+func (d *Dataset) Search(query string) ([]Person, []float64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if strings.TrimSpace(query) == "" {
+		return append([]Person(nil), d.persons...), make([]float64, len(d.persons)), nil
+	}
+	docs, scores, err := d.index.Search(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	persons := make([]Person, 0, len(docs))
+	for _, doc := range docs {
+		persons = append(persons, d.persons[doc])
+	}
+	return persons, scores, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoaders(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cases := []struct {
+		path    string
+		format  string
+		columns map[string]string
+	}{
+		{path: write("people.json", `[{"id": 7, "first_name": "Ann", "last_name": "Lee", "age": 30, "about": "x", "gender": "female"}]`)},
+		{path: write("people.jsonl", `{"id": 7, "first_name": "Ann", "last_name": "Lee", "age": 30, "about": "x", "gender": "female"}`+"\n")},
+		{path: write("people.txt", "id,first_name,last_name,age,about,gender\n7,Ann,Lee,30,x,female\n"), format: "csv"},
+		{
+			path:    write("mapped.csv", "ID,Given,Family,Years,Bio,Sex\n7,Ann,Lee,30,x,female\n"),
+			columns: map[string]string{"id": "ID", "first_name": "Given", "last_name": "Family", "age": "Years", "about": "Bio", "gender": "Sex"},
+		},
+	}
+	expected := Person{Name: "Ann Lee", FirstName: "Ann", LastName: "Lee", Id: 7, Age: 30, About: "x", Gender: "female"}
+
+	for caseNum, item := range cases {
+		loader, err := LoaderFor(item.path, item.format, item.columns)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		d := NewDataset(item.path, loader)
+		if err := d.Reload(); err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		if persons := d.Persons(); len(persons) != 1 || persons[0] != expected {
+			t.Errorf("case %d: wrong persons\nGot: %+v\nExpected: %+v", caseNum, persons, expected)
+		}
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(path, []byte("id,age\n1,old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoaderFor("people.yaml", "", nil); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if err := NewDataset(path, CSVLoader{Columns: map[string]string{"gender": "Sex"}}).Reload(); err == nil || !strings.Contains(err.Error(), `column "Sex"`) {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+	if err := NewDataset(path, CSVLoader{}).Reload(); err == nil || !strings.Contains(err.Error(), `bad age "old"`) {
+		t.Errorf("expected bad age error, got %v", err)
+	}
+	if _, err := ParseColumns("id=ID,nickname=Nick"); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestDatasetReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.jsonl")
+	if err := os.WriteFile(path, []byte(`{"id": 1, "first_name": "Ann"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDataset(path, JSONLinesLoader)
+	if err := d.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"id": 1}`+"\n"+`{"id": 2}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.Persons()) != 2 {
+		t.Errorf("dataset was not reloaded: %+v", d.Persons())
+	}
+
+	if err := os.WriteFile(path, []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reload(); err == nil {
+		t.Errorf("expected error for broken file")
+	}
+	if len(d.Persons()) != 2 {
+		t.Errorf("failed reload replaced data: %+v", d.Persons())
+	}
+}
+
+func TestReloadServer(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = NewDataset("dataset.xml", XMLLoader)
+
+	ts := httptest.NewServer(http.HandlerFunc(ReloadServer))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, nil)
+	req.Header.Set("AccessToken", accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("wrong status\nGot: %d\nExpected: %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if len(dataset.Persons()) != 35 {
+		t.Errorf("wrong persons count\nGot: %d\nExpected: 35", len(dataset.Persons()))
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// personField описывает поле Person, доступное в фильтрах и сортировке: у строковых полей num == nil.
+type personField struct {
+	str func(p *Person) string
+	num func(p *Person) int
+}
+
+var personFields = map[string]personField{
+	"id":         {num: func(p *Person) int { return p.Id }},
+	"age":        {num: func(p *Person) int { return p.Age }},
+	"name":       {str: func(p *Person) string { return p.Name }},
+	"first_name": {str: func(p *Person) string { return p.FirstName }},
+	"last_name":  {str: func(p *Person) string { return p.LastName }},
+	"about":      {str: func(p *Person) string { return p.About }},
+	"gender":     {str: func(p *Person) string { return p.Gender }},
+}
+
+// FilterError — ошибка разбора фильтра, Pos — номер символа (с 1), на котором она найдена.
+type FilterError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter: %s at %d", e.Msg, e.Pos)
+}
+
+// filterNode — узел дерева фильтра.
+type filterNode interface {
+	match(p *Person) bool
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) match(p *Person) bool { return n.left.match(p) && n.right.match(p) }
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) match(p *Person) bool { return n.left.match(p) || n.right.match(p) }
+
+type notNode struct{ x filterNode }
+
+func (n notNode) match(p *Person) bool { return !n.x.match(p) }
+
+// cmpNode сравнивает поле с значением. Для строк ":" — вхождение подстроки, "=" и "!=" — равенство,
+// всё без учёта регистра. Для чисел ":" означает то же, что "=".
+type cmpNode struct {
+	field personField
+	op    string
+	str   string
+	num   int
+}
+
+func (n cmpNode) match(p *Person) bool {
+	if n.field.num != nil {
+		val := n.field.num(p)
+		switch n.op {
+		case ":", "=":
+			return val == n.num
+		case "!=":
+			return val != n.num
+		case ">":
+			return val > n.num
+		case ">=":
+			return val >= n.num
+		case "<":
+			return val < n.num
+		default:
+			return val <= n.num
+		}
+	}
+
+	val := strings.ToLower(n.field.str(p))
+	switch n.op {
+	case ":":
+		return strings.Contains(val, n.str)
+	case "=":
+		return val == n.str
+	default:
+		return val != n.str
+	}
+}
+
+// parseFilter разбирает фильтр вида `age>=30 gender:female (about:"labore" OR NOT name:bo)`.
+// Условия через пробел объединяются по AND, есть OR, NOT и скобки. Пустой фильтр пропускает всё.
+func parseFilter(src string) (filterNode, error) {
+	tokens, err := lexFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, nil
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &FilterError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexFilter(src string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{tokLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{tokRParen, ")", pos})
+			i++
+		case strings.ContainsRune(":=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != ':' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &FilterError{Pos: pos, Msg: `expected "!="`}
+			}
+			tokens = append(tokens, filterToken{tokOp, op, pos})
+			i += len(op)
+		case r == '"':
+			sb := strings.Builder{}
+			end := i + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				sb.WriteRune(runes[end])
+			}
+			if end == len(runes) {
+				return nil, &FilterError{Pos: pos, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, filterToken{tokString, sb.String(), pos})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`():=!<>"`, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{tokWord, string(runes[i:end]), pos})
+			i = end
+		}
+	}
+	return append(tokens, filterToken{tokEOF, "end of filter", len(runes) + 1}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokWord && tok.text == word
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.isKeyword("AND") {
+			p.next()
+		} else if tok := p.peek(); tok.kind == tokEOF || tok.kind == tokRParen || p.isKeyword("OR") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &FilterError{Pos: closing.pos, Msg: fmt.Sprintf(`expected ")", got %q`, closing.text)}
+		}
+		return node, nil
+	case tokWord:
+		return p.parseComparison(tok)
+	default:
+		return nil, &FilterError{Pos: tok.pos, Msg: fmt.Sprintf("expected condition, got %q", tok.text)}
+	}
+}
+
+func (p *filterParser) parseComparison(name filterToken) (filterNode, error) {
+	field, ok := personFields[name.text]
+	if !ok {
+		return nil, &FilterError{Pos: name.pos, Msg: fmt.Sprintf("unknown field %q", name.text)}
+	}
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, &FilterError{Pos: op.pos, Msg: fmt.Sprintf("expected operator after %q, got %q", name.text, op.text)}
+	}
+	val := p.next()
+	if val.kind != tokWord && val.kind != tokString {
+		return nil, &FilterError{Pos: val.pos, Msg: fmt.Sprintf("expected value, got %q", val.text)}
+	}
+
+	node := cmpNode{field: field, op: op.text}
+	if field.num != nil {
+		num, err := strconv.Atoi(val.text)
+		if err != nil {
+			return nil, &FilterError{Pos: val.pos, Msg: fmt.Sprintf("field %q expects a number, got %q", name.text, val.text)}
+		}
+		node.num = num
+		return node, nil
+	}
+	if op.text != ":" && op.text != "=" && op.text != "!=" {
+		return nil, &FilterError{Pos: op.pos, Msg: fmt.Sprintf("operator %q is not supported for field %q", op.text, name.text)}
+	}
+	node.str = strings.ToLower(val.text)
+	return node, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Filter — условие на поля записи для SearchRequest.Filter. Пустой Filter пропускает все записи.
+//
+//	And(FieldAge.Ge(30), FieldGender.Eq("female"), FieldAbout.Contains("labore"))
+type Filter struct {
+	expr string
+	op   string // внешний оператор выражения: "AND", "OR" или "" для простого условия
+}
+
+// StringField — строковое поле записи, сравнивается без учёта регистра.
+type StringField string
+
+// IntField — числовое поле записи.
+type IntField string
+
+// Поля, на которые можно ставить условия. Тип поля задаёт, какие сравнения для него есть.
+const (
+	FieldId        IntField    = "id"
+	FieldAge       IntField    = "age"
+	FieldName      StringField = "name"
+	FieldFirstName StringField = "first_name"
+	FieldLastName  StringField = "last_name"
+	FieldAbout     StringField = "about"
+	FieldGender    StringField = "gender"
+)
+
+func cmp(field, op, val string) Filter {
+	return Filter{expr: field + op + val}
+}
+
+// Eq — поле равно s.
+func (f StringField) Eq(s string) Filter { return cmp(string(f), "=", quoteFilter(s)) }
+
+// Ne — поле не равно s.
+func (f StringField) Ne(s string) Filter { return cmp(string(f), "!=", quoteFilter(s)) }
+
+// Contains — поле содержит подстроку s.
+func (f StringField) Contains(s string) Filter { return cmp(string(f), ":", quoteFilter(s)) }
+
+func (f IntField) Eq(n int) Filter { return cmp(string(f), "=", strconv.Itoa(n)) }
+func (f IntField) Ne(n int) Filter { return cmp(string(f), "!=", strconv.Itoa(n)) }
+func (f IntField) Gt(n int) Filter { return cmp(string(f), ">", strconv.Itoa(n)) }
+func (f IntField) Ge(n int) Filter { return cmp(string(f), ">=", strconv.Itoa(n)) }
+func (f IntField) Lt(n int) Filter { return cmp(string(f), "<", strconv.Itoa(n)) }
+func (f IntField) Le(n int) Filter { return cmp(string(f), "<=", strconv.Itoa(n)) }
+
+// And выполняется, когда выполнены все filters.
+func And(filters ...Filter) Filter { return join("AND", filters) }
+
+// Or выполняется, когда выполнен хотя бы один из filters.
+func Or(filters ...Filter) Filter { return join("OR", filters) }
+
+// Not выполняется, когда не выполнен f.
+func Not(f Filter) Filter {
+	if f.expr == "" {
+		return f
+	}
+	return Filter{expr: "NOT " + f.group()}
+}
+
+func join(op string, filters []Filter) Filter {
+	var nonEmpty []Filter
+	for _, f := range filters {
+		if f.expr != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		// оператор сохраняется, иначе следующий group не возьмёт выражение в скобки
+		return nonEmpty[0]
+	}
+	parts := make([]string, 0, len(nonEmpty))
+	for _, f := range nonEmpty {
+		if f.op == op {
+			parts = append(parts, f.expr)
+		} else {
+			parts = append(parts, f.group())
+		}
+	}
+	return Filter{expr: strings.Join(parts, " "+op+" "), op: op}
+}
+
+// group берёт составное выражение в скобки, чтобы его можно было вложить в другое.
+func (f Filter) group() string {
+	if f.op == "" {
+		return f.expr
+	}
+	return "(" + f.expr + ")"
+}
+
+// String возвращает фильтр в виде, который понимает SearchServer.
+func (f Filter) String() string {
+	return f.expr
+}
+
+func quoteFilter(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var filterPersons = []Person{
+	{Id: 0, Name: "Boyd Wolf", Age: 22, Gender: "male", About: "Nulla cillum labore"},
+	{Id: 1, Name: "Hilda Mayer", Age: 21, Gender: "female", About: "Sit commodo"},
+	{Id: 2, Name: "Brooks Aguilar", Age: 25, Gender: "male", About: "Velit ullamco"},
+	{Id: 3, Name: "Beth Wynn", Age: 31, Gender: "female", About: "Labore et \"dolore\""},
+}
+
+func TestParseFilter(t *testing.T) {
+	cases := []struct {
+		filter   string
+		expected []int
+	}{
+		{``, []int{0, 1, 2, 3}},
+		{`age>=25`, []int{2, 3}},
+		{`age>=30 gender:female about:"labore"`, []int{3}},
+		{`gender=FEMALE AND age<31`, []int{1}},
+		{`gender:female OR age=25`, []int{1, 2, 3}},
+		{`NOT (gender:female OR age=25)`, []int{0}},
+		{`(name:wolf OR name:wynn) age!=22`, []int{3}},
+		{`gender!=male age>21 OR id:0`, []int{0, 3}},
+		{`about:"\"dolore\""`, []int{3}},
+	}
+	for _, item := range cases {
+		node, err := parseFilter(item.filter)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", item.filter, err)
+			continue
+		}
+		got := []int{}
+		for i := range filterPersons {
+			if node == nil || node.match(&filterPersons[i]) {
+				got = append(got, filterPersons[i].Id)
+			}
+		}
+		if !reflect.DeepEqual(got, item.expected) {
+			t.Errorf("%q: wrong results\nGot: %v\nExpected: %v", item.filter, got, item.expected)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := []struct {
+		filter string
+		pos    int
+	}{
+		{`height>3`, 1},
+		{`age>=x`, 6},
+		{`age 30`, 5},
+		{`name>bob`, 5},
+		{`(age>3`, 7},
+		{`age>3)`, 6},
+		{`about:"open`, 7},
+		{`age>3 OR`, 9},
+		{`age!3`, 4},
+	}
+	for _, item := range cases {
+		_, err := parseFilter(item.filter)
+		filterErr, ok := err.(*FilterError)
+		if !ok {
+			t.Errorf("%q: expected FilterError, got %v", item.filter, err)
+			continue
+		}
+		if filterErr.Pos != item.pos {
+			t.Errorf("%q: wrong position\nGot: %d (%v)\nExpected: %d", item.filter, filterErr.Pos, err, item.pos)
+		}
+	}
+}
+
+func TestFilterBuilder(t *testing.T) {
+	f := And(
+		FieldAge.Ge(30),
+		FieldGender.Eq("female"),
+		Or(FieldAbout.Contains(`say "hi"`), Not(And(FieldId.Lt(3), FieldName.Ne("bob")))),
+		Filter{},
+	)
+	expected := `age>=30 AND gender="female" AND (about:"say \"hi\"" OR NOT (id<3 AND name!="bob"))`
+	if f.String() != expected {
+		t.Errorf("wrong filter\nGot: %s\nExpected: %s", f, expected)
+	}
+	if _, err := parseFilter(f.String()); err != nil {
+		t.Errorf("built filter does not parse: %v", err)
+	}
+
+	// пустой операнд не должен терять скобки вокруг единственного оставшегося выражения
+	a, b, c := FieldAge.Ge(30), FieldGender.Eq("female"), FieldId.Eq(1)
+	cases := []struct {
+		filter   Filter
+		expected string
+	}{
+		{And(Or(Or(a, b), Filter{}), c), `(age>=30 OR gender="female") AND id=1`},
+		{Not(And(And(a, b))), `NOT (age>=30 AND gender="female")`},
+		{Or(Filter{}, FieldAge.Ne(5)), `age!=5`},
+	}
+	for caseNum, item := range cases {
+		if item.filter.String() != item.expected {
+			t.Errorf("case %d: wrong filter\nGot: %s\nExpected: %s", caseNum, item.filter, item.expected)
+		}
+	}
+}
+
+func TestFilterFieldKinds(t *testing.T) {
+	// типы полей клиента должны совпадать с тем, как их разбирает сервер
+	for _, f := range []IntField{FieldId, FieldAge} {
+		if field, ok := personFields[string(f)]; !ok || field.num == nil {
+			t.Errorf("%s is not a numeric field on the server", f)
+		}
+	}
+	for _, f := range []StringField{FieldName, FieldFirstName, FieldLastName, FieldAbout, FieldGender} {
+		if field, ok := personFields[string(f)]; !ok || field.str == nil {
+			t.Errorf("%s is not a string field on the server", f)
+		}
+	}
+	if len(personFields) != 7 {
+		t.Errorf("client fields out of date: server has %d fields", len(personFields))
+	}
+}
+
+func TestSearchServerFilter(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(
+		Person{Id: 0, FirstName: "Ann", Age: 35, Gender: "female"},
+		Person{Id: 1, FirstName: "Bob", Age: 40, Gender: "male"},
+		Person{Id: 2, FirstName: "Eve", Age: 20, Gender: "female"},
+	)
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	resp, err := srv.FindUsers(SearchRequest{Limit: 10, OrderField: "id", OrderBy: OrderByAsc,
+		Filter: And(FieldAge.Ge(30), FieldGender.Eq("female"))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Id != 0 {
+		t.Errorf("wrong users: %+v", resp.Users)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"?order_by=0&filter="+url.QueryEscape("age>=30 colour:red"), nil)
+	req.Header.Set("AccessToken", accessToken)
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	errResp := SearchErrorResponse{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&errResp); err != nil {
+		t.Fatal(err)
+	}
+	if httpResp.StatusCode != http.StatusBadRequest || errResp.Position != 9 {
+		t.Errorf("wrong error response\nGot: %d %+v\nExpected: 400 with position 9", httpResp.StatusCode, errResp)
+	}
+
+	_, err = srv.FindUsers(SearchRequest{Limit: 10, Filter: Filter{expr: "age>"}})
+	if err == nil || !strings.Contains(err.Error(), "bad filter") {
+		t.Errorf("expected bad filter error, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// fieldGap — пропуск позиций между Name и About, чтобы фраза не склеивалась из двух полей
+	fieldGap = 1000
+)
+
+// Index — инвертированный индекс по Name и About для поиска по словам с ранжированием BM25.
+type Index struct {
+	postings map[string][]posting
+	docLen   []int
+	avgLen   float64
+}
+
+// posting — вхождения слова в одну запись.
+type posting struct {
+	doc       int
+	positions []int
+}
+
+// NewIndex строит индекс по persons, номера документов совпадают с индексами в срезе.
+func NewIndex(persons []Person) *Index {
+	idx := &Index{postings: make(map[string][]posting), docLen: make([]int, len(persons))}
+	total := 0
+	for doc, person := range persons {
+		pos := 0
+		for _, field := range []string{person.Name, person.About} {
+			terms := tokenize(field)
+			for i, term := range terms {
+				idx.add(term, doc, pos+i)
+			}
+			idx.docLen[doc] += len(terms)
+			pos += len(terms) + fieldGap
+		}
+		total += idx.docLen[doc]
+	}
+	if len(persons) > 0 {
+		idx.avgLen = float64(total) / float64(len(persons))
+	}
+	return idx
+}
+
+func (idx *Index) add(term string, doc, pos int) {
+	list := idx.postings[term]
+	if n := len(list); n > 0 && list[n-1].doc == doc {
+		list[n-1].positions = append(list[n-1].positions, pos)
+		return
+	}
+	idx.postings[term] = append(list, posting{doc: doc, positions: []int{pos}})
+}
+
+// Search возвращает номера подходящих под query документов по убыванию релевантности и их оценки.
+func (idx *Index) Search(query string) ([]int, []float64, error) {
+	q, err := parseTextQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matched := make(map[int]bool)
+	for _, clause := range q {
+		for doc := range idx.matchClause(clause) {
+			matched[doc] = true
+		}
+	}
+
+	var terms []string
+	for _, clause := range q {
+		for _, phrase := range clause {
+			terms = append(terms, phrase...)
+		}
+	}
+
+	docs := make([]int, 0, len(matched))
+	scores := make(map[int]float64, len(matched))
+	for doc := range matched {
+		docs = append(docs, doc)
+		scores[doc] = idx.score(doc, terms)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+
+	result := make([]float64, len(docs))
+	for i, doc := range docs {
+		result[i] = scores[doc]
+	}
+	return docs, result, nil
+}
+
+// matchClause находит документы, в которых есть все фразы clause.
+func (idx *Index) matchClause(clause []textPhrase) map[int]bool {
+	var docs map[int]bool
+	for _, phrase := range clause {
+		found := idx.matchPhrase(phrase)
+		if docs == nil {
+			docs = found
+			continue
+		}
+		for doc := range docs {
+			if !found[doc] {
+				delete(docs, doc)
+			}
+		}
+	}
+	return docs
+}
+
+// matchPhrase находит документы, где слова phrase идут подряд.
+func (idx *Index) matchPhrase(phrase textPhrase) map[int]bool {
+	docs := make(map[int]bool)
+	for _, first := range idx.postings[phrase[0]] {
+		for _, start := range first.positions {
+			if idx.hasSequence(first.doc, phrase[1:], start+1) {
+				docs[first.doc] = true
+				break
+			}
+		}
+	}
+	return docs
+}
+
+func (idx *Index) hasSequence(doc int, terms []string, pos int) bool {
+	for i, term := range terms {
+		list := idx.postings[term]
+		at := sort.Search(len(list), func(k int) bool { return list[k].doc >= doc })
+		if at == len(list) || list[at].doc != doc {
+			return false
+		}
+		positions := list[at].positions
+		p := sort.SearchInts(positions, pos+i)
+		if p == len(positions) || positions[p] != pos+i {
+			return false
+		}
+	}
+	return true
+}
+
+// score считает BM25 документа doc по словам запроса.
+func (idx *Index) score(doc int, terms []string) float64 {
+	n := float64(len(idx.docLen))
+	score := 0.0
+	for _, term := range terms {
+		list := idx.postings[term]
+		at := sort.Search(len(list), func(k int) bool { return list[k].doc >= doc })
+		if at == len(list) || list[at].doc != doc {
+			continue
+		}
+		df := float64(len(list))
+		tf := float64(len(list[at].positions))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := 1 - bm25B + bm25B*float64(idx.docLen[doc])/idx.avgLen
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return score
+}
+
+// textPhrase — слова, которые должны идти подряд; одно слово — тоже фраза.
+type textPhrase []string
+
+// textQuery — дизъюнкция конъюнкций: документ подходит, если подходит хотя бы одна группа,
+// а группа — если в документе есть все её фразы.
+type textQuery [][]textPhrase
+
+// parseTextQuery разбирает запрос: слова через пробел — AND (слово AND можно писать явно),
+// OR разделяет группы, "слова в кавычках" — фраза.
+func parseTextQuery(query string) (textQuery, error) {
+	var (
+		q      textQuery
+		clause []textPhrase
+	)
+	closeClause := func(pos int) error {
+		if len(clause) == 0 {
+			return fmt.Errorf("empty operand of OR at %d", pos)
+		}
+		q = append(q, clause)
+		clause = nil
+		return nil
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated phrase at %d", i)
+			}
+			if terms := tokenize(string(runes[i+1 : end])); len(terms) > 0 {
+				clause = append(clause, terms)
+			}
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			switch word {
+			case "OR":
+				if err := closeClause(i); err != nil {
+					return nil, err
+				}
+			case "AND":
+			default:
+				for _, term := range tokenize(word) {
+					clause = append(clause, textPhrase{term})
+				}
+			}
+			i = end
+		}
+	}
+	if len(q) > 0 || len(clause) > 0 {
+		if err := closeClause(len(runes)); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// tokenize разбивает s на слова из букв и цифр, приводя их к нижнему регистру и убирая диакритику.
+func tokenize(s string) []string {
+	var terms []string
+	sb := strings.Builder{}
+	flush := func() {
+		if sb.Len() > 0 {
+			terms = append(terms, sb.String())
+			sb.Reset()
+		}
+	}
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(fold(unicode.ToLower(r)))
+		} else if !unicode.Is(unicode.Mn, r) { // комбинируемые диакритические знаки просто пропускаем
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+var foldTable = func() map[rune]rune {
+	table := make(map[rune]rune)
+	for base, variants := range map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįı",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		'r': "ŕŗř",
+		's': "śŝşšß",
+		't': "ţťŧ",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'y': "ýÿŷ",
+		'z': "źżž",
+		'е': "ё",
+	} {
+		for _, r := range variants {
+			table[r] = base
+		}
+	}
+	return table
+}()
+
+// fold убирает диакритику у строчной буквы.
+func fold(r rune) rune {
+	if base, ok := foldTable[r]; ok {
+		return base
+	}
+	return r
+}
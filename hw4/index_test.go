@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testDataset(persons ...Person) *Dataset {
+	d := &Dataset{}
+	d.set(persons)
+	return d
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Crème Brûlée, naïve-CAFÉ éclair Ёлка 42")
+	expected := []string{"creme", "brulee", "naive", "cafe", "eclair", "елка", "42"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong tokens\nGot: %v\nExpected: %v", got, expected)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	d := testDataset(
+		Person{Id: 0, FirstName: "Boyd", LastName: "Wolf", About: "Nulla cillum enim voluptate"},
+		Person{Id: 1, FirstName: "Hilda", LastName: "Mayer", About: "Sit commodo consectetur minim amet ex"},
+		Person{Id: 2, FirstName: "Brooks", LastName: "Aguilar", About: "Velit ullamco est sit amet, sit amet sit"},
+		Person{Id: 3, FirstName: "Wolf", LastName: "Nulla", About: "Amet sit"},
+	)
+
+	cases := []struct {
+		query    string
+		expected []int
+	}{
+		{"wolf", []int{3, 0}},
+		{"WOLF nulla", []int{3, 0}},
+		{"wolf AND cillum", []int{0}},
+		{"hilda OR brooks", []int{1, 2}},
+		{`"sit amet"`, []int{2}},
+		{`"amet sit"`, []int{2, 3}},
+		{`"wolf nulla"`, []int{3}},
+		{"sit", []int{2, 3, 1}},
+		{"missing", []int{}},
+	}
+	for _, item := range cases {
+		persons, _, err := d.Search(item.query)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", item.query, err)
+			continue
+		}
+		got := []int{}
+		for _, person := range persons {
+			got = append(got, person.Id)
+		}
+		if !reflect.DeepEqual(got, item.expected) {
+			t.Errorf("%q: wrong results\nGot: %v\nExpected: %v", item.query, got, item.expected)
+		}
+	}
+
+	for _, query := range []string{`"sit amet`, "wolf OR", "OR wolf", "wolf OR OR nulla"} {
+		if _, _, err := d.Search(query); err == nil {
+			t.Errorf("%q: expected error", query)
+		}
+	}
+}
+
+func TestSearchServerRelevance(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(
+		Person{Id: 0, FirstName: "Ann", About: "labore"},
+		Person{Id: 1, FirstName: "Bob", About: "labore labore labore"},
+		Person{Id: 2, FirstName: "Eve", About: "nothing here"},
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	resp, err := srv.FindUsers(SearchRequest{Limit: 10, Text: "labore", OrderField: fieldRelevance, OrderBy: OrderByDesc})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 2 || resp.Users[0].Id != 1 || resp.Users[1].Id != 0 {
+		t.Errorf("wrong relevance order: %+v", resp.Users)
+	}
+
+	_, err = srv.FindUsers(SearchRequest{Limit: 10, Text: `"labore`})
+	if err == nil || !strings.Contains(err.Error(), "cant unpack result json") {
+		t.Errorf("expected bad request for broken query, got %v", err)
+	}
+}
+
+func TestSearchServerTextBeforeReload(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = NewDataset("dataset.xml", XMLLoader)
+
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	resp, err := srv.FindUsers(SearchRequest{Limit: 10, Text: "labore"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 0 || resp.NextPage {
+		t.Errorf("not loaded dataset must be empty\nGot: %+v", resp)
+	}
+}
+
+func TestSearchServerQuerySubstring(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = NewDataset("dataset.xml", XMLLoader)
+	if err := dataset.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	// query остаётся подстрокой с учётом регистра, слова и релевантность — в Text
+	cases := []struct {
+		req      SearchRequest
+		expected int
+	}{
+		{SearchRequest{Limit: 25, Query: "Boy"}, 1},
+		{SearchRequest{Limit: 25, Query: "boy"}, 0},
+		{SearchRequest{Limit: 25, Query: "nulla"}, 14},
+		{SearchRequest{Limit: 25, Text: "nulla"}, 17},
+		{SearchRequest{Limit: 25, Query: "nulla", Text: "wolf"}, 1},
+	}
+	for caseNum, item := range cases {
+		resp, err := srv.FindUsers(item.req)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		if len(resp.Users) != item.expected {
+			t.Errorf("case %d: wrong count\nGot: %d\nExpected: %d", caseNum, len(resp.Users), item.expected)
+		}
+	}
+}
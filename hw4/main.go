@@ -2,14 +2,15 @@ package main
 
 import (
 	"encoding/json"
-	"encoding/xml"
 	"errors"
-	"fmt"
+	"flag"
+	"log"
 	"net/http"
 	"os"
-	"sort"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 type Person struct {
@@ -20,112 +21,154 @@ type Person struct {
 	Age       int    `xml:"age"`
 	About     string `xml:"about"`
 	Gender    string `xml:"gender"`
+	// Extra — остальные элементы строки dataset.xml как есть, их возвращает в файл XMLSaver
+	Extra string `xml:"-" json:"-"`
 }
 
 const (
 	fieldName = "name"
 	fieldId   = "id"
 	fieldAge  = "age"
+
+	// fieldRelevance сортирует по релевантности полнотекстовому запросу text, доступна только в SearchServer
+	fieldRelevance = "relevance"
 )
 
 const accessToken = "clown_token"
 
-type Root struct {
-	Persons []Person `xml:"row"`
-}
-
-var root Root
-
-func Parse() {
-	byteValue, err := os.ReadFile("dataset.xml")
-	if err != nil {
-		fmt.Printf("Error reading file: %s", err)
-	}
+var dataset = NewDataset("dataset.xml", XMLLoader)
 
-	err = xml.Unmarshal(byteValue, &root)
-	if err != nil {
-		fmt.Printf("Error unmarshaling XML: %s", err)
-	}
-
-	for i, person := range root.Persons {
-		root.Persons[i].Name = fmt.Sprintf("%s %s", person.FirstName, person.LastName)
-	}
+// Parse загружает dataset.xml из рабочей директории.
+func Parse() error {
+	return dataset.Reload()
 }
 
+// SortBy сортирует по полю или списку полей вида "age:desc,name:asc", см. parseOrder.
+// Сортировка устойчивая, записи, равные по всем полям, идут по возрастанию Id.
 func SortBy(persons *[]Person, field string, by int) error {
-	if len(field) == 0 {
-		field = "name"
-	}
-	switch field {
-	case fieldName:
-		if by == OrderByAsc {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Name < (*persons)[j].Name })
-		} else {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Name > (*persons)[j].Name })
-		}
-	case fieldId:
-		if by == OrderByAsc {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Id < (*persons)[j].Id })
-		} else {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Id > (*persons)[j].Id })
-		}
-	case fieldAge:
-		if by == OrderByAsc {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Age < (*persons)[j].Age })
-		} else {
-			sort.Slice(*persons, func(i, j int) bool { return (*persons)[i].Age > (*persons)[j].Age })
+	spec, err := parseOrder(field, by)
+	if err != nil {
+		return err
+	}
+	for _, term := range spec {
+		if term.field == fieldRelevance {
+			// оценки есть только у результатов поиска, их сортирует SearchServer
+			return errors.New(ErrorBadOrderField)
 		}
-	default:
-		return errors.New(ErrorBadOrderField)
 	}
+	spec.sort(*persons, nil)
 	return nil
 }
 
-func SearchServer(w http.ResponseWriter, r *http.Request) {
-	var persons []Person
+func writeSearchError(w http.ResponseWriter, response *SearchErrorResponse) {
+	w.WriteHeader(http.StatusBadRequest)
+	errorText, _ := json.Marshal(response)
+	_, _ = w.Write(errorText)
+}
 
+func SearchServer(w http.ResponseWriter, r *http.Request) {
 	if accessToken != r.Header.Get("AccessToken") {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	query := r.URL.Query().Get("query")
+	text := r.URL.Query().Get("text")
 	orderField := r.URL.Query().Get("order_field")
 	orderBy := r.URL.Query().Get("order_by")
 	limit := r.URL.Query().Get("limit")
 	offset := r.URL.Query().Get("offset")
+	filterSrc := r.URL.Query().Get("filter")
 
-	for _, person := range root.Persons {
-		if strings.Contains(person.Name, query) || strings.Contains(person.About, query) {
-			persons = append(persons, person)
+	filter, err := parseFilter(filterSrc)
+	if err != nil {
+		response := &SearchErrorResponse{Error: err.Error()}
+		var filterErr *FilterError
+		if errors.As(err, &filterErr) {
+			response.Position = filterErr.Pos
 		}
+		writeSearchError(w, response)
+		return
 	}
 
+	persons, relevance, err := dataset.Search(text)
+	if err != nil {
+		writeSearchError(w, &SearchErrorResponse{Error: "text: " + err.Error()})
+		return
+	}
+	scores := make(map[int]float64, len(persons))
+	for i := range persons {
+		scores[persons[i].Id] = relevance[i]
+	}
+	matched := persons[:0]
+	for i := range persons {
+		// query — подстрока в Name или About с учётом регистра, как и раньше
+		if !strings.Contains(persons[i].Name, query) && !strings.Contains(persons[i].About, query) {
+			continue
+		}
+		if filter == nil || filter.match(&persons[i]) {
+			matched = append(matched, persons[i])
+		}
+	}
+	persons = matched
+
 	order, err := strconv.Atoi(orderBy)
 	if err != nil || order < -1 || order > 1 {
 		http.Error(w, "order incorrect", http.StatusBadRequest)
 	}
 
-	err = SortBy(&persons, orderField, order)
+	spec, err := parseOrder(orderField, order)
 	if err != nil {
-		response := &SearchErrorResponse{Error: err.Error()}
-		w.WriteHeader(http.StatusBadRequest)
-		errorText, _ := json.Marshal(response)
-		_, _ = w.Write(errorText)
+		writeSearchError(w, &SearchErrorResponse{Error: err.Error()})
 		return
 	}
+	spec.sort(persons, scores)
 
 	offsetInt, err := strconv.Atoi(offset)
 	if err != nil || offsetInt < 0 {
 		offsetInt = 0
 	}
+	// курсор задаёт начало страницы вместо offset и не сбивается, если записи до него добавили или удалили
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := decodeCursor(token, cursorSecret)
+		if err == nil && (cursor.Order != spec.String() || cursor.Query != query || cursor.Text != text || cursor.Filter != filterSrc) {
+			err = errors.New("cursor does not match request")
+		}
+		if err != nil {
+			writeSearchError(w, &SearchErrorResponse{Error: "cursor: " + err.Error()})
+			return
+		}
+		offsetInt = startAfter(persons, spec, cursor, scores)
+	}
+	if offsetInt > len(persons) {
+		offsetInt = len(persons)
+	}
 
 	limitInt, err := strconv.Atoi(limit)
-	if err != nil || limitInt <= 0 {
-		limitInt = len(persons)
+	if err != nil || limitInt <= 0 || offsetInt+limitInt > len(persons) {
+		limitInt = len(persons) - offsetInt
+	}
+
+	// с lookahead=1 клиент просит на одну запись больше, чтобы узнать о следующей странице,
+	// и отбрасывает её, поэтому курсор ставится после предпоследней записи
+	pageEnd := offsetInt + limitInt
+	if r.URL.Query().Get("lookahead") == "1" {
+		if n, err := strconv.Atoi(limit); err == nil && n > 0 && n == limitInt {
+			pageEnd--
+		}
 	}
 
-	result := persons[offsetInt:limitInt]
+	result := persons[offsetInt : offsetInt+limitInt]
+	if pageEnd < len(persons) {
+		if pageEnd > offsetInt {
+			next := searchCursor{Order: spec.String(), Query: query, Text: text, Filter: filterSrc,
+				After: spec.keyOf(&persons[pageEnd-1], scores)}
+			w.Header().Set(cursorHeader, next.encode(cursorSecret))
+		} else if token := r.URL.Query().Get("cursor"); token != "" {
+			// страница пустая, следующая начинается там же
+			w.Header().Set(cursorHeader, token)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(result)
@@ -135,8 +178,70 @@ func SearchServer(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ReloadServer перечитывает датасет по POST-запросу.
+func ReloadServer(w http.ResponseWriter, r *http.Request) {
+	if accessToken != r.Header.Get("AccessToken") {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := dataset.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func main() {
-	Parse()
+	path := flag.String("data", "dataset.xml", "dataset file")
+	format := flag.String("format", "", "dataset format: xml, json, jsonl or csv (by file extension if empty)")
+	columns := flag.String("csv-columns", "", "CSV column mapping, e.g. id=ID,first_name=First Name")
+	addr := flag.String("addr", ":8080", "listen address")
+	secret := flag.String("cursor-secret", "", "key for signing pagination cursors (random if empty)")
+	writeThrough := flag.Bool("write-through", false, "save changes made via /persons back to the dataset file")
+	flag.Parse()
+
+	if *secret != "" {
+		cursorSecret = []byte(*secret)
+	}
+
+	mapping, err := ParseColumns(*columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := LoaderFor(*path, *format, mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dataset = NewDataset(*path, loader)
+	if *writeThrough {
+		saver, err := SaverFor(*path, *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dataset.WriteThrough(saver)
+	}
+	if err := dataset.Reload(); err != nil {
+		log.Fatal(err)
+	}
+
+	// kill -HUP перечитывает датасет
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := dataset.Reload(); err != nil {
+				log.Printf("reload failed: %s", err)
+			}
+		}
+	}()
+
 	http.HandleFunc("/", SearchServer)
-	_ = http.ListenAndServe(":8080", nil)
+	http.HandleFunc("/reload", ReloadServer)
+	http.HandleFunc(personsPath, PersonsServer)
+	http.HandleFunc(personsPath+"/", PersonsServer)
+	log.Fatal(http.ListenAndServe(*addr, nil))
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// orderTerm — одно поле сортировки с направлением.
+type orderTerm struct {
+	field string
+	asc   bool
+}
+
+// orderSpec — поля сортировки по убыванию приоритета. Записи, равные по всем полям, идут по возрастанию id.
+type orderSpec []orderTerm
+
+// parseOrder разбирает order_field вида "age:desc,name:asc,gender". Поле без направления
+// сортируется по by: по возрастанию только при OrderByAsc. Пустая строка — сортировка по name.
+// Кроме полей Person (см. personFields) можно указать relevance.
+func parseOrder(src string, by int) (orderSpec, error) {
+	if strings.TrimSpace(src) == "" {
+		src = fieldName
+	}
+	var spec orderSpec
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(src, ",") {
+		parts := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		term := orderTerm{field: strings.ToLower(parts[0]), asc: by == OrderByAsc}
+		if _, ok := personFields[term.field]; !ok && term.field != fieldRelevance || seen[term.field] {
+			return nil, errors.New(ErrorBadOrderField)
+		}
+		seen[term.field] = true
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+				term.asc = true
+			case "desc":
+				term.asc = false
+			default:
+				return nil, errors.New(ErrorBadOrderField)
+			}
+		}
+		spec = append(spec, term)
+	}
+	return spec, nil
+}
+
+// String возвращает spec в каноническом виде, направление указано у каждого поля.
+func (spec orderSpec) String() string {
+	parts := make([]string, len(spec))
+	for i, term := range spec {
+		parts[i] = term.field + ":desc"
+		if term.asc {
+			parts[i] = term.field + ":asc"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// sortValue — значение одного поля сортировки: число или строка.
+type sortValue struct {
+	Num float64 `json:"n,omitempty"`
+	Str string  `json:"s,omitempty"`
+}
+
+// sortKey — место записи в выдаче: значения полей сортировки и id.
+type sortKey struct {
+	Values []sortValue `json:"v"`
+	Id     int         `json:"id"`
+}
+
+// keyOf возвращает ключ сортировки p, для релевантности оценка берётся из scores по id.
+func (spec orderSpec) keyOf(p *Person, scores map[int]float64) sortKey {
+	key := sortKey{Values: make([]sortValue, len(spec)), Id: p.Id}
+	for i, term := range spec {
+		if term.field == fieldRelevance {
+			key.Values[i].Num = scores[p.Id]
+			continue
+		}
+		f := personFields[term.field]
+		if f.num != nil {
+			key.Values[i].Num = float64(f.num(p))
+		} else {
+			key.Values[i].Str = f.str(p)
+		}
+	}
+	return key
+}
+
+// before сообщает, стоит ли a раньше b. Равные по всем полям записи упорядочены по id,
+// поэтому порядок полный и курсор однозначно указывает место, даже если саму запись удалили.
+func (spec orderSpec) before(a, b sortKey) bool {
+	for i, term := range spec {
+		x, y := a.Values[i], b.Values[i]
+		if x.Num != y.Num {
+			return (x.Num < y.Num) == term.asc
+		}
+		if x.Str != y.Str {
+			return (x.Str < y.Str) == term.asc
+		}
+	}
+	return a.Id < b.Id
+}
+
+// sort сортирует persons, ключи считаются один раз на запись.
+func (spec orderSpec) sort(persons []Person, scores map[int]float64) {
+	keys := make([]sortKey, len(persons))
+	for i := range persons {
+		keys[i] = spec.keyOf(&persons[i], scores)
+	}
+	sort.Stable(byKeys{persons, keys, spec})
+}
+
+type byKeys struct {
+	persons []Person
+	keys    []sortKey
+	spec    orderSpec
+}
+
+func (s byKeys) Len() int           { return len(s.persons) }
+func (s byKeys) Less(i, j int) bool { return s.spec.before(s.keys[i], s.keys[j]) }
+func (s byKeys) Swap(i, j int) {
+	s.persons[i], s.persons[j] = s.persons[j], s.persons[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrder(t *testing.T) {
+	cases := []struct {
+		src      string
+		by       int
+		expected string
+	}{
+		{src: "", by: OrderByAsc, expected: "name:asc"},
+		{src: "age", by: OrderByAsIs, expected: "age:desc"},
+		{src: "age:desc, Name:ASC", by: OrderByAsc, expected: "age:desc,name:asc"},
+		{src: "gender,last_name:desc,id", by: OrderByAsc, expected: "gender:asc,last_name:desc,id:asc"},
+		{src: "relevance,id:asc", by: OrderByDesc, expected: "relevance:desc,id:asc"},
+	}
+	for caseNum, item := range cases {
+		spec, err := parseOrder(item.src, item.by)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		if spec.String() != item.expected {
+			t.Errorf("case %d: wrong order\nGot: %s\nExpected: %s", caseNum, spec, item.expected)
+		}
+	}
+
+	for _, src := range []string{"colour", "age:up", "age,age:desc", "name,", "age:asc:desc"} {
+		if _, err := parseOrder(src, OrderByAsc); err == nil || err.Error() != ErrorBadOrderField {
+			t.Errorf("%q: expected %q, got %v", src, ErrorBadOrderField, err)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	persons := []Person{
+		{Id: 1, LastName: "Lee", Age: 30, Gender: "male"},
+		{Id: 2, LastName: "Kim", Age: 20, Gender: "female"},
+		{Id: 3, LastName: "Lee", Age: 40, Gender: "female"},
+		{Id: 4, LastName: "Ash", Age: 30, Gender: "male"},
+		{Id: 5, LastName: "Kim", Age: 30, Gender: "female"},
+	}
+	cases := []struct {
+		field    string
+		by       int
+		expected []int
+	}{
+		{field: "age:desc,last_name:asc", expected: []int{3, 4, 5, 1, 2}},
+		{field: "gender,age", by: OrderByAsc, expected: []int{2, 5, 3, 1, 4}},
+		{field: "last_name", by: OrderByDesc, expected: []int{1, 3, 2, 5, 4}},
+		{field: "id", by: OrderByAsc, expected: []int{1, 2, 3, 4, 5}},
+	}
+	for caseNum, item := range cases {
+		sorted := append([]Person(nil), persons...)
+		if err := SortBy(&sorted, item.field, item.by); err != nil {
+			t.Errorf("case %d: unexpected error: %v", caseNum, err)
+			continue
+		}
+		var got []int
+		for _, p := range sorted {
+			got = append(got, p.Id)
+		}
+		if !reflect.DeepEqual(got, item.expected) {
+			t.Errorf("case %d: wrong order for %q\nGot: %v\nExpected: %v", caseNum, item.field, got, item.expected)
+		}
+	}
+
+	if err := SortBy(&persons, fieldRelevance, OrderByDesc); err == nil {
+		t.Errorf("expected error for relevance without search")
+	}
+}
+
+func TestSearchServerMultiOrder(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(
+		Person{Id: 1, FirstName: "Ann", LastName: "Lee", Age: 30},
+		Person{Id: 2, FirstName: "Bob", LastName: "Kim", Age: 20},
+		Person{Id: 3, FirstName: "Eve", LastName: "Ash", Age: 30},
+		Person{Id: 4, FirstName: "Kim", LastName: "Kim", Age: 30},
+	)
+	ts := httptest.NewServer(http.HandlerFunc(SearchServer))
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL}
+
+	req := SearchRequest{Limit: 3, OrderField: "age:desc,last_name", OrderBy: OrderByAsc}
+	var got []int
+	for {
+		resp, err := srv.FindUsers(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, u := range resp.Users {
+			got = append(got, u.Id)
+		}
+		if !resp.NextPage {
+			break
+		}
+		req.Cursor = resp.NextCursor
+	}
+	if expected := []int{3, 4, 1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong order\nGot: %v\nExpected: %v", got, expected)
+	}
+
+	_, err := srv.FindUsers(SearchRequest{Limit: 3, OrderField: "age:sideways"})
+	if err == nil || err.Error() != "OrderFeld age:sideways invalid" {
+		t.Errorf("expected bad order field error, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const personsPath = "/persons"
+
+// personPatch — тело запросов на запись, поля совпадают с personRecord. Отсутствующее поле
+// в POST и PUT считается пустым, в PATCH — не меняется.
+type personPatch struct {
+	Id        *int    `json:"id"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Age       *int    `json:"age"`
+	About     *string `json:"about"`
+	Gender    *string `json:"gender"`
+}
+
+func (patch personPatch) apply(p *Person) {
+	if patch.FirstName != nil {
+		p.FirstName = *patch.FirstName
+	}
+	if patch.LastName != nil {
+		p.LastName = *patch.LastName
+	}
+	if patch.Age != nil {
+		p.Age = *patch.Age
+	}
+	if patch.About != nil {
+		p.About = *patch.About
+	}
+	if patch.Gender != nil {
+		p.Gender = *patch.Gender
+	}
+}
+
+func validatePerson(p *Person) error {
+	switch {
+	case strings.TrimSpace(p.FirstName) == "":
+		return errors.New("first_name is required")
+	case strings.TrimSpace(p.LastName) == "":
+		return errors.New("last_name is required")
+	case p.Age < 0 || p.Age > 150:
+		return fmt.Errorf("age %d is out of range 0..150", p.Age)
+	case p.Gender != "male" && p.Gender != "female":
+		return fmt.Errorf("gender must be male or female, got %q", p.Gender)
+	}
+	return nil
+}
+
+// badRequestError — ошибка в данных запроса, отдаётся клиенту как 400.
+type badRequestError struct{ error }
+
+// PersonsServer изменяет датасет:
+//
+//	POST /persons — создать запись, без id в теле назначается новый;
+//	PUT /persons/{id} — заменить запись целиком;
+//	PATCH /persons/{id} — изменить переданные поля;
+//	DELETE /persons/{id} — удалить.
+//
+// Отвечает изменённой (или удалённой) записью в том же виде, что и SearchServer.
+func PersonsServer(w http.ResponseWriter, r *http.Request) {
+	if accessToken != r.Header.Get("AccessToken") {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, personsPath), "/")
+	id := -1
+	if rest != "" {
+		var err error
+		if id, err = strconv.Atoi(rest); err != nil || id < 0 {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if (r.Method == http.MethodPost) != (id < 0) {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var patch personPatch
+	if r.Method != http.MethodDelete {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&patch); err != nil {
+			writeSearchError(w, &SearchErrorResponse{Error: "bad body: " + err.Error()})
+			return
+		}
+		if id >= 0 && patch.Id != nil && *patch.Id != id {
+			writeSearchError(w, &SearchErrorResponse{Error: fmt.Sprintf("id %d in body does not match %d in path", *patch.Id, id)})
+			return
+		}
+	}
+
+	var (
+		person Person
+		err    error
+	)
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodPost:
+		person.Id = -1
+		if patch.Id != nil {
+			person.Id = *patch.Id
+		}
+		patch.apply(&person)
+		if err = validatePerson(&person); err != nil {
+			err = badRequestError{err}
+			break
+		}
+		person, err = dataset.Create(person)
+		status = http.StatusCreated
+	case http.MethodPut, http.MethodPatch:
+		person, err = dataset.Update(id, func(p *Person) error {
+			if r.Method == http.MethodPut {
+				// элементы dataset.xml, которых нет в API, PUT не трогает
+				*p = Person{Id: id, Extra: p.Extra}
+			}
+			patch.apply(p)
+			if err := validatePerson(p); err != nil {
+				return badRequestError{err}
+			}
+			return nil
+		})
+	case http.MethodDelete:
+		person, err = dataset.Delete(id)
+	}
+
+	var badRequest badRequestError
+	switch {
+	case errors.As(err, &badRequest):
+		writeSearchError(w, &SearchErrorResponse{Error: badRequest.Error()})
+		return
+	case errors.Is(err, ErrPersonNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrPersonExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(person)
+}
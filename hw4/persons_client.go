@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UserData — поля пользователя для CreateUser и UpdateUser, Id назначает сервер.
+type UserData struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Age       int    `json:"age"`
+	About     string `json:"about"`
+	Gender    string `json:"gender"`
+}
+
+// UserPatch — изменяемые поля для PatchUser, nil — оставить как есть.
+type UserPatch struct {
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+	Age       *int    `json:"age,omitempty"`
+	About     *string `json:"about,omitempty"`
+	Gender    *string `json:"gender,omitempty"`
+}
+
+// CreateUser добавляет пользователя и возвращает его с назначенным Id.
+func (srv *SearchClient) CreateUser(data UserData) (*User, error) {
+	return srv.writeUser(http.MethodPost, -1, data)
+}
+
+// UpdateUser заменяет все поля пользователя id.
+func (srv *SearchClient) UpdateUser(id int, data UserData) (*User, error) {
+	return srv.writeUser(http.MethodPut, id, data)
+}
+
+// PatchUser меняет только заданные в patch поля пользователя id.
+func (srv *SearchClient) PatchUser(id int, patch UserPatch) (*User, error) {
+	return srv.writeUser(http.MethodPatch, id, patch)
+}
+
+// DeleteUser удаляет пользователя id.
+func (srv *SearchClient) DeleteUser(id int) error {
+	_, err := srv.writeUser(http.MethodDelete, id, nil)
+	return err
+}
+
+// writeUser отправляет запрос на /persons внешней системы, id < 0 — без id в пути.
+func (srv *SearchClient) writeUser(method string, id int, body interface{}) (*User, error) {
+	target := strings.TrimSuffix(srv.URL, "/") + "/persons"
+	if id >= 0 {
+		target += "/" + strconv.Itoa(id)
+	}
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("unknown error %s", err)
+	}
+	req.Header.Add("AccessToken", srv.AccessToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			return nil, fmt.Errorf("timeout for %s %s", method, target)
+		}
+		return nil, fmt.Errorf("unknown error %s", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cant read response: %s", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("Bad AccessToken")
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("user %d not found", id)
+	case http.StatusConflict:
+		return nil, fmt.Errorf("user already exists")
+	case http.StatusBadRequest:
+		errResp := SearchErrorResponse{}
+		if err := json.Unmarshal(respBody, &errResp); err != nil {
+			return nil, fmt.Errorf("cant unpack error json: %s", err)
+		}
+		return nil, fmt.Errorf("bad user data: %s", errResp.Error)
+	case http.StatusInternalServerError:
+		return nil, fmt.Errorf("SearchServer fatal error")
+	default:
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	user := User{}
+	if err := json.Unmarshal(respBody, &user); err != nil {
+		return nil, fmt.Errorf("cant unpack result json: %s", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPersonsServer(t *testing.T) {
+	saved := dataset
+	defer func() { dataset = saved }()
+	dataset = testDataset(Person{Id: 3, FirstName: "Ann", LastName: "Lee", Age: 30, Gender: "female"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", SearchServer)
+	mux.HandleFunc(personsPath, PersonsServer)
+	mux.HandleFunc(personsPath+"/", PersonsServer)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	srv := SearchClient{AccessToken: accessToken, URL: ts.URL + "/"}
+
+	user, err := srv.CreateUser(UserData{FirstName: "Bob", LastName: "Stone", Age: 40, About: "falconer", Gender: "male"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := User{Id: 4, Name: "Bob Stone", Age: 40, About: "falconer", Gender: "male"}
+	if *user != expected {
+		t.Errorf("wrong created user\nGot: %+v\nExpected: %+v", *user, expected)
+	}
+
+	// индекс перестроен: новая запись находится поиском
+	resp, err := srv.FindUsers(SearchRequest{Limit: 10, Query: "falconer"})
+	if err != nil || len(resp.Users) != 1 || resp.Users[0].Id != 4 {
+		t.Errorf("created user is not searchable: %+v, %v", resp, err)
+	}
+
+	age := 41
+	if user, err = srv.PatchUser(4, UserPatch{Age: &age}); err != nil || user.Age != 41 || user.About != "falconer" {
+		t.Errorf("wrong patched user: %+v, %v", user, err)
+	}
+	if user, err = srv.UpdateUser(4, UserData{FirstName: "Rob", LastName: "Stone", Gender: "male"}); err != nil || user.Name != "Rob Stone" || user.Age != 0 || user.About != "" {
+		t.Errorf("wrong updated user: %+v, %v", user, err)
+	}
+	if resp, _ := srv.FindUsers(SearchRequest{Limit: 10, Query: "falconer"}); len(resp.Users) != 0 {
+		t.Errorf("stale index after update: %+v", resp.Users)
+	}
+	if err := srv.DeleteUser(4); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(dataset.Persons()) != 1 {
+		t.Errorf("user was not deleted: %+v", dataset.Persons())
+	}
+
+	errCases := []struct {
+		do       func() error
+		expected string
+	}{
+		{
+			do: func() error {
+				_, err := srv.CreateUser(UserData{FirstName: "Eve", LastName: "Moss", Gender: "robot"})
+				return err
+			},
+			expected: `bad user data: gender must be male or female, got "robot"`,
+		},
+		{
+			do: func() error {
+				_, err := srv.PatchUser(3, UserPatch{FirstName: new(string)})
+				return err
+			},
+			expected: "bad user data: first_name is required",
+		},
+		{
+			do:       func() error { return srv.DeleteUser(4) },
+			expected: "user 4 not found",
+		},
+		{
+			do: func() error {
+				bad := SearchClient{AccessToken: "bad", URL: srv.URL}
+				_, err := bad.CreateUser(UserData{})
+				return err
+			},
+			expected: "Bad AccessToken",
+		},
+	}
+	for caseNum, item := range errCases {
+		if err := item.do(); err == nil || err.Error() != item.expected {
+			t.Errorf("case %d: wrong error\nGot: %v\nExpected: %s", caseNum, err, item.expected)
+		}
+	}
+
+	rawCases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, personsPath, `{"id": 3, "first_name": "A", "last_name": "B", "gender": "male"}`, http.StatusConflict},
+		{http.MethodPost, personsPath, `{"first_name": "A", "nickname": "B"}`, http.StatusBadRequest},
+		{http.MethodPut, personsPath + "/3", `{"id": 5, "first_name": "A", "last_name": "B", "gender": "male"}`, http.StatusBadRequest},
+		{http.MethodPost, personsPath + "/3", `{}`, http.StatusMethodNotAllowed},
+		{http.MethodDelete, personsPath, ``, http.StatusMethodNotAllowed},
+		{http.MethodGet, personsPath + "/3", ``, http.StatusMethodNotAllowed},
+		{http.MethodDelete, personsPath + "/abc", ``, http.StatusNotFound},
+	}
+	for caseNum, item := range rawCases {
+		req, _ := http.NewRequest(item.method, ts.URL+item.path, strings.NewReader(item.body))
+		req.Header.Set("AccessToken", accessToken)
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		if httpResp.StatusCode != item.status {
+			t.Errorf("case %d: wrong status\nGot: %d\nExpected: %d", caseNum, httpResp.StatusCode, item.status)
+		}
+	}
+}
+
+func TestDatasetWriteThrough(t *testing.T) {
+	for _, name := range []string{"people.json", "people.jsonl", "people.xml"} {
+		path := filepath.Join(t.TempDir(), name)
+		saver, err := SaverFor(path, "")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		loader, _ := LoaderFor(path, "", nil)
+
+		d := NewDataset(path, loader)
+		d.WriteThrough(saver)
+		if _, err := d.Create(Person{Id: -1, FirstName: "Ann", LastName: "Lee", Age: 30, About: "x", Gender: "female"}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if _, err := d.Create(Person{Id: -1, FirstName: "Bob", LastName: "Kim", Gender: "male"}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if _, err := d.Delete(0); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		reloaded := NewDataset(path, loader)
+		if err := reloaded.Reload(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		expected := Person{Name: "Bob Kim", FirstName: "Bob", LastName: "Kim", Id: 1, Gender: "male"}
+		if persons := reloaded.Persons(); len(persons) != 1 || persons[0] != expected {
+			t.Errorf("%s: wrong saved persons\nGot: %+v\nExpected: %+v", name, persons, expected)
+		}
+	}
+
+	if _, err := SaverFor("people.csv", ""); err == nil {
+		t.Errorf("expected error for csv")
+	}
+
+	// не удалось сохранить — данные в памяти не меняются
+	d := testDataset()
+	d.path = filepath.Join(t.TempDir(), "missing", "people.json")
+	d.WriteThrough(JSONSaver)
+	if _, err := d.Create(Person{Id: -1}); err == nil {
+		t.Errorf("expected save error")
+	}
+	if len(d.Persons()) != 0 {
+		t.Errorf("failed save changed data: %+v", d.Persons())
+	}
+	if _, err := os.Stat(d.path); !os.IsNotExist(err) {
+		t.Errorf("unexpected file: %v", err)
+	}
+}
+
+func TestXMLWriteThroughKeepsRows(t *testing.T) {
+	src, err := os.ReadFile("dataset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "dataset.xml")
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDataset(path, XMLLoader)
+	if err := d.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	before := append([]Person(nil), d.Persons()...)
+
+	d.WriteThrough(XMLSaver)
+	if _, err := d.Update(0, func(p *Person) error { p.Age = 23; return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"<guid>", "<isActive>", "<balance>", "<email>", "<registered>", "<favoriteFruit>"} {
+		if got, expected := strings.Count(string(saved), tag), strings.Count(string(src), tag); got != expected {
+			t.Errorf("%s lost on save\nGot: %d\nExpected: %d", tag, got, expected)
+		}
+	}
+
+	reloaded := NewDataset(path, XMLLoader)
+	if err := reloaded.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	before[0].Age = 23
+	if !reflect.DeepEqual(reloaded.Persons(), before) {
+		t.Errorf("persons changed after save")
+	}
+}
+
+func TestDatasetConcurrentWrites(t *testing.T) {
+	d := testDataset()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := d.Create(Person{Id: -1, FirstName: "Ann"})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if _, err := d.Update(p.Id, func(p *Person) error { p.Age++; return nil }); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if _, _, err := d.Search("ann"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, p := range d.Persons() {
+		if seen[p.Id] || p.Age != 1 {
+			t.Errorf("wrong person after concurrent writes: %+v", p)
+		}
+		seen[p.Id] = true
+	}
+	if len(seen) != 20 {
+		t.Errorf("wrong persons count\nGot: %d\nExpected: 20", len(seen))
+	}
+}